@@ -0,0 +1,408 @@
+package audit
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+const (
+    // runsTable holds one row per job run
+    runsTable = "job_runs"
+    // changesTable holds the rows each run changed, if AUDIT_ROWS is set
+    changesTable = "job_changes"
+)
+
+// Statuses a run can end in; it is running until then.
+const (
+    Running   = "running"
+    Succeeded = "succeeded"
+    Failed    = "failed"
+)
+
+// Job describes a run as it starts. Source and Dest name the environment
+// variables the job connects with, never the URLs themselves, which hold
+// passwords. A DryRun rolls its changes back, so they are counted but
+// never logged as rows.
+type Job struct {
+    Name   string
+    Source string
+    Dest   string
+    Params map[string]interface{}
+    DryRun bool
+}
+
+// Change is one row a run changed, with its values before (nil for an
+// insert) and after (nil for a delete).
+type Change struct {
+    Table  string                 `json:"table"`
+    Op     string                 `json:"op"`
+    Key    interface{}            `json:"key"`
+    Before map[string]interface{} `json:"before,omitempty"`
+    After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// Run records one run of a job into job_runs. A nil Run does nothing, so
+// code that may or may not be audited can call it either way.
+type Run struct {
+    ID int64
+
+    db     *sql.DB
+    job    string
+    rows   bool
+    dryRun bool
+    mu     sync.Mutex
+    counts map[string]int64
+    // pending are the changes not yet written to job_changes, and seq
+    // the number of those that have been
+    pending []Change
+    seq     int
+    // report is where every change is written at the end, if anywhere
+    report   string
+    started  time.Time
+    reported []Change
+}
+
+// RowsFromEnv reports whether AUDIT_ROWS asks for every changed row to be
+// logged, not just the counts.
+func RowsFromEnv() bool {
+    rows, _ := strconv.ParseBool(os.Getenv("AUDIT_ROWS"))
+    return rows
+}
+
+// Start records that job has started, in db's job_runs.
+func Start(db *sql.DB, job Job) (*Run, error) {
+    if job.DryRun {
+        job.Params = copyParams(job.Params)
+        job.Params["dry_run"] = true
+    }
+    params, err := json.Marshal(job.Params)
+    if err != nil {
+        return nil, err
+    }
+    r := &Run{db: db, job: job.Name, rows: RowsFromEnv() && !job.DryRun, dryRun: job.DryRun, counts: make(map[string]int64), started: time.Now().UTC()}
+    err = db.QueryRow(fmt.Sprintf(`
+        INSERT INTO %s (job, params, source, dest, status) VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`, runsTable), job.Name, string(params), job.Source, job.Dest, Running).Scan(&r.ID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to record start of %s: %w", job.Name, err)
+    }
+    if job.DryRun {
+        log.Printf("Started %s run %d as a dry run", job.Name, r.ID)
+    } else {
+        log.Printf("Started %s run %d", job.Name, r.ID)
+    }
+    return r, nil
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+    copied := make(map[string]interface{}, len(params)+1)
+    for k, v := range params {
+        copied[k] = v
+    }
+    return copied
+}
+
+// DryRun reports whether the run's changes are rolled back.
+func (r *Run) DryRun() bool {
+    return r != nil && r.dryRun
+}
+
+// Add adds n to the count called name, e.g. "h3_level_3 written".
+func (r *Run) Add(name string, n int64) {
+    if r == nil {
+        return
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.counts[name] += n
+}
+
+// Rows reports whether changed rows are being logged or reported, so
+// callers can skip building them otherwise. They are never logged on a
+// dry run.
+func (r *Run) Rows() bool {
+    return r != nil && (r.rows || r.report != "")
+}
+
+// Record counts a changed row under "<table> <op>", keeps it for the
+// next Flush if AUDIT_ROWS is set and for the report if there is one.
+func (r *Run) Record(c Change) {
+    if r == nil {
+        return
+    }
+    r.Add(c.Table+" "+c.Op, 1)
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.rows {
+        r.pending = append(r.pending, c)
+    }
+    if r.report != "" {
+        r.reported = append(r.reported, c)
+    }
+}
+
+// Flush writes the rows recorded since the last Flush to job_changes in
+// tx. A job calls it in the transaction that made the changes, so the log
+// holds exactly the changes that were committed, even if the job dies
+// before it finishes.
+func (r *Run) Flush(tx *sql.Tx) error {
+    if r == nil {
+        return nil
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if err := r.writeChanges(tx); err != nil {
+        return err
+    }
+    r.pending = nil
+    return nil
+}
+
+// writeChanges copies the pending changes into job_changes, numbered after
+// the ones already written.
+func (r *Run) writeChanges(tx *sql.Tx) error {
+    if len(r.pending) == 0 {
+        return nil
+    }
+    stmt, err := tx.Prepare(pq.CopyIn(changesTable, "run_id", "seq", "table_name", "op", "key", "before", "after"))
+    if err != nil {
+        return err
+    }
+    for i, c := range r.pending {
+        before, err := jsonOrNil(c.Before)
+        if err != nil {
+            stmt.Close()
+            return err
+        }
+        after, err := jsonOrNil(c.After)
+        if err != nil {
+            stmt.Close()
+            return err
+        }
+        if _, err := stmt.Exec(r.ID, r.seq+i+1, c.Table, c.Op, fmt.Sprint(c.Key), before, after); err != nil {
+            stmt.Close()
+            return fmt.Errorf("failed to log changes of run %d: %w", r.ID, err)
+        }
+    }
+    if _, err := stmt.Exec(); err != nil {
+        stmt.Close()
+        return fmt.Errorf("failed to log changes of run %d: %w", r.ID, err)
+    }
+    if err := stmt.Close(); err != nil {
+        return err
+    }
+    r.seq += len(r.pending)
+    return nil
+}
+
+// Finish records how the run ended, with err nil for success, and any
+// rows recorded since the last Flush. Those are dropped if the run failed,
+// since they belong to the transaction that was rolled back.
+func (r *Run) Finish(err error) error {
+    if r == nil {
+        return nil
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    status, message := Succeeded, ""
+    if err != nil {
+        status, message = Failed, err.Error()
+    }
+    counts, jsonErr := json.Marshal(r.counts)
+    if jsonErr != nil {
+        return jsonErr
+    }
+
+    tx, txErr := r.db.Begin()
+    if txErr != nil {
+        return txErr
+    }
+    defer tx.Rollback()
+
+    _, txErr = tx.Exec(fmt.Sprintf(`
+        UPDATE %s SET finished_at = now(), counts = $1, status = $2, error = NULLIF($3, '')
+        WHERE id = $4`, runsTable), string(counts), status, message, r.ID)
+    if txErr != nil {
+        return fmt.Errorf("failed to record end of run %d: %w", r.ID, txErr)
+    }
+
+    if err == nil {
+        if err := r.writeChanges(tx); err != nil {
+            return err
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    r.pending = nil
+
+    log.Printf("Finished %s run %d: %s, %s", r.job, r.ID, status, formatCounts(r.counts))
+
+    if r.report != "" {
+        if err := r.writeReport(); err != nil {
+            return fmt.Errorf("failed to write report of run %d: %w", r.ID, err)
+        }
+        log.Printf("Report of run %d written to %s", r.ID, r.report)
+    }
+    return nil
+}
+
+// Done records how the run ended like Finish and returns err, so a job
+// can end with return run.Done(err). Failing to record the end is only
+// returned if the job itself succeeded.
+func (r *Run) Done(err error) error {
+    if finishErr := r.Finish(err); finishErr != nil {
+        if err != nil {
+            log.Printf("Failed to record run failure: %v", finishErr)
+            return err
+        }
+        return fmt.Errorf("failed to finish audit log: %w", finishErr)
+    }
+    return err
+}
+
+func jsonOrNil(row map[string]interface{}) (interface{}, error) {
+    if row == nil {
+        return nil, nil
+    }
+    data, err := json.Marshal(row)
+    if err != nil {
+        return nil, err
+    }
+    return string(data), nil
+}
+
+func formatCounts(counts map[string]int64) string {
+    if len(counts) == 0 {
+        return "no changes"
+    }
+    names := make([]string, 0, len(counts))
+    for name := range counts {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    s := ""
+    for i, name := range names {
+        if i > 0 {
+            s += ", "
+        }
+        s += fmt.Sprintf("%s %d", name, counts[name])
+    }
+    return s
+}
+
+// RunInfo is a recorded run as listed by Recent.
+type RunInfo struct {
+    ID         int64
+    Job        string
+    StartedAt  time.Time
+    FinishedAt *time.Time
+    Params     map[string]interface{}
+    Source     string
+    Dest       string
+    Counts     map[string]int64
+    Status     string
+    Error      string
+}
+
+// CountsString lists the run's counts for display.
+func (i RunInfo) CountsString() string {
+    return formatCounts(i.Counts)
+}
+
+// Recent returns the latest limit runs, newest first, of job or of every
+// job if it is "".
+func Recent(db *sql.DB, job string, limit int) ([]RunInfo, error) {
+    return queryRuns(db, "WHERE $1 = '' OR job = $1 ORDER BY started_at DESC, id DESC LIMIT $2", job, limit)
+}
+
+// Get returns run id, or nil if there is none.
+func Get(db *sql.DB, id int64) (*RunInfo, error) {
+    runs, err := queryRuns(db, "WHERE id = $1", id)
+    if err != nil || len(runs) == 0 {
+        return nil, err
+    }
+    return &runs[0], nil
+}
+
+func queryRuns(db *sql.DB, where string, args ...interface{}) ([]RunInfo, error) {
+    rows, err := db.Query(fmt.Sprintf(`
+        SELECT id, job, started_at, finished_at, params, source, dest, counts, status, error
+        FROM %s %s`, runsTable, where), args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var runs []RunInfo
+    for rows.Next() {
+        var info RunInfo
+        var finished sql.NullTime
+        var params, counts []byte
+        var source, dest, message sql.NullString
+        if err := rows.Scan(&info.ID, &info.Job, &info.StartedAt, &finished, &params, &source, &dest, &counts, &info.Status, &message); err != nil {
+            return nil, err
+        }
+        if finished.Valid {
+            info.FinishedAt = &finished.Time
+        }
+        info.Source, info.Dest, info.Error = source.String, dest.String, message.String
+        if len(params) > 0 {
+            if err := json.Unmarshal(params, &info.Params); err != nil {
+                return nil, err
+            }
+        }
+        if len(counts) > 0 {
+            if err := json.Unmarshal(counts, &info.Counts); err != nil {
+                return nil, err
+            }
+        }
+        runs = append(runs, info)
+    }
+    return runs, rows.Err()
+}
+
+// Changes returns the rows run id changed, in the order it changed them.
+// It is empty unless the run had AUDIT_ROWS set.
+func Changes(db *sql.DB, id int64) ([]Change, error) {
+    rows, err := db.Query(fmt.Sprintf(`
+        SELECT table_name, op, key, before, after FROM %s
+        WHERE run_id = $1 ORDER BY seq`, changesTable), id)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var changes []Change
+    for rows.Next() {
+        var c Change
+        var key string
+        var before, after []byte
+        if err := rows.Scan(&c.Table, &c.Op, &key, &before, &after); err != nil {
+            return nil, err
+        }
+        c.Key = key
+        if len(before) > 0 {
+            if err := json.Unmarshal(before, &c.Before); err != nil {
+                return nil, err
+            }
+        }
+        if len(after) > 0 {
+            if err := json.Unmarshal(after, &c.After); err != nil {
+                return nil, err
+            }
+        }
+        changes = append(changes, c)
+    }
+    return changes, rows.Err()
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "time"
+)
+
+// Report is the JSON diff a run writes with ReportTo: every row it
+// changed, or on a dry run would have changed, by table and op.
+type Report struct {
+    Job        string                                 `json:"job"`
+    RunID      int64                                  `json:"run_id"`
+    DryRun     bool                                   `json:"dry_run"`
+    StartedAt  time.Time                              `json:"started_at"`
+    FinishedAt time.Time                              `json:"finished_at"`
+    Tables     map[string]map[string][]ReportedChange `json:"tables"`
+}
+
+// ReportedChange is a Change within its table and op in a Report.
+type ReportedChange struct {
+    Key    interface{}            `json:"key"`
+    Before map[string]interface{} `json:"before,omitempty"`
+    After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// ReportTo has the run write every row it records to path as a Report
+// when it finishes, whether or not AUDIT_ROWS is set. "" writes none.
+func (r *Run) ReportTo(path string) {
+    if r == nil {
+        return
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.report = path
+}
+
+// writeReport saves the report; the caller holds r.mu.
+func (r *Run) writeReport() error {
+    report := Report{
+        Job:        r.job,
+        RunID:      r.ID,
+        DryRun:     r.dryRun,
+        StartedAt:  r.started,
+        FinishedAt: time.Now().UTC(),
+        Tables:     make(map[string]map[string][]ReportedChange),
+    }
+    for _, c := range r.reported {
+        ops, ok := report.Tables[c.Table]
+        if !ok {
+            ops = make(map[string][]ReportedChange)
+            report.Tables[c.Table] = ops
+        }
+        ops[c.Op] = append(ops[c.Op], ReportedChange{Key: c.Key, Before: c.Before, After: c.After})
+    }
+    data, err := json.MarshalIndent(report, "", "  ")
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(r.report, data, 0644)
+}
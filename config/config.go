@@ -0,0 +1,52 @@
+package config
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+
+    "github.com/joho/godotenv"
+    _ "github.com/lib/pq"
+)
+
+// DefaultEnvFile is read when no other file is given.
+const DefaultEnvFile = ".env"
+
+// Load reads environment variables from path. Variables already set win,
+// and a missing file is fine, since the same settings can come from the
+// environment itself.
+func Load(path string) error {
+    if path == "" {
+        path = DefaultEnvFile
+    }
+    if _, err := os.Stat(path); os.IsNotExist(err) {
+        log.Printf("No %s file found, using environment variables", path)
+        return nil
+    }
+    if err := godotenv.Load(path); err != nil {
+        return fmt.Errorf("failed to load %s: %w", path, err)
+    }
+    return nil
+}
+
+// DB opens the database whose URL is in the environment variable envVar.
+// Jobs are told which variable to use rather than the URL itself, so
+// passwords stay out of flags, logs and the audit log.
+func DB(envVar string) (*sql.DB, error) {
+    dbURL, err := URL(envVar)
+    if err != nil {
+        return nil, err
+    }
+    return sql.Open("postgres", dbURL)
+}
+
+// URL returns the database URL in envVar, for the few jobs that need to
+// open their own connections.
+func URL(envVar string) (string, error) {
+    dbURL := os.Getenv(envVar)
+    if dbURL == "" {
+        return "", fmt.Errorf("%s not set in environment variables", envVar)
+    }
+    return dbURL, nil
+}
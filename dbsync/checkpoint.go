@@ -0,0 +1,58 @@
+package dbsync
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+)
+
+// checkpointTable holds, per named sync, the identity values of the last
+// source row it wrote as a JSON array.
+const checkpointTable = "sync_checkpoints"
+
+// checkpoint returns the identity values the sync called name stopped
+// after, nil to start from the beginning.
+func checkpoint(db *sql.DB, name string) ([]string, error) {
+    if name == "" {
+        return nil, nil
+    }
+    var key string
+    err := db.QueryRow(fmt.Sprintf("SELECT last_key FROM %s WHERE name = $1", checkpointTable), name).Scan(&key)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var values []string
+    if err := json.Unmarshal([]byte(key), &values); err != nil {
+        return nil, fmt.Errorf("checkpoint %q is not a JSON array of identity values: %w", key, err)
+    }
+    return values, nil
+}
+
+// setCheckpoint records the identity values of the last row in the same
+// transaction as the batch that wrote it, so a sync that dies never skips
+// or redoes a batch.
+func setCheckpoint(tx *sql.Tx, name string, last []string) error {
+    if name == "" {
+        return nil
+    }
+    key, err := json.Marshal(last)
+    if err != nil {
+        return err
+    }
+    _, err = tx.Exec(fmt.Sprintf(`
+        INSERT INTO %s (name, last_key, updated_at) VALUES ($1, $2, now())
+        ON CONFLICT (name) DO UPDATE SET last_key = EXCLUDED.last_key, updated_at = EXCLUDED.updated_at`, checkpointTable), name, string(key))
+    return err
+}
+
+// clearCheckpoint starts the next run of name from the beginning again.
+func clearCheckpoint(db *sql.DB, name string) error {
+    if name == "" {
+        return nil
+    }
+    _, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE name = $1", checkpointTable), name)
+    return err
+}
@@ -0,0 +1,492 @@
+package dbsync
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "main/audit"
+)
+
+// Resolve is what happens when a source row's key is already in the
+// destination.
+type Resolve int
+
+const (
+    InsertOnly Resolve = iota // leave the destination row alone
+    NewerWins                 // update if the source's By time is later
+    HigherWins                // update if the source's By number is larger
+)
+
+// DefaultBatchSize is how many rows a sync reads and writes at a time.
+const DefaultBatchSize = 500
+
+// Filter keeps only source rows whose Column is at least Min.
+type Filter struct {
+    Column string
+    Min    interface{}
+}
+
+// Policy declares how one table is copied from a source database to a
+// destination. A source row is the same place as the destination row
+// whose Key and Match columns all agree; those columns are only ever
+// written by an insert, and an update changes the rest. Together they
+// should be unique in the source, which is read in their order. A row is inserted only if its Key isn't in
+// the destination at all, so a place at the same Key under another Match
+// is left alone. Columns are copied as they are, Key included.
+type Policy struct {
+    // Name is what the sync's checkpoint is stored under. Without one
+    // every run starts from the beginning.
+    Name    string
+    Table   string
+    Key     string
+    Columns []string
+    Resolve Resolve
+    By      string
+    Match   []string
+    Filters []Filter
+    // BatchSize is how many source rows are read and written per
+    // transaction, DefaultBatchSize if 0.
+    BatchSize int
+    // MaxChanges stops the sync after that many inserts and updates, 0
+    // for no limit. The next run resumes where this one stopped.
+    MaxChanges int
+    // AfterUpdate, if set, runs in the same transaction after each
+    // update, for tables derived from the synced one. Anything it writes
+    // should be recorded in run.
+    AfterUpdate func(tx *sql.Tx, change audit.Change, run *audit.Run) error
+}
+
+// Row is one row by column name.
+type Row map[string]interface{}
+
+func (r Row) values(columns []string) []interface{} {
+    values := make([]interface{}, len(columns))
+    for i, c := range columns {
+        values[i] = r[c]
+    }
+    return values
+}
+
+// Options control a Run.
+type Options struct {
+    // DryRun rolls every batch back instead of committing it.
+    DryRun bool
+    // Audit, if set, is told about every change, and its report shows
+    // them.
+    Audit *audit.Run
+}
+
+// OptionsFromEnv reads SYNC_DRY_RUN.
+func OptionsFromEnv() Options {
+    dryRun, _ := strconv.ParseBool(os.Getenv("SYNC_DRY_RUN"))
+    return Options{DryRun: dryRun}
+}
+
+func (p Policy) batchSize() int {
+    if p.BatchSize > 0 {
+        return p.BatchSize
+    }
+    return DefaultBatchSize
+}
+
+// identity is the columns that pick out one destination row.
+func (p Policy) identity() []string {
+    return append([]string{p.Key}, p.Match...)
+}
+
+// isIdentity reports whether column is one of the identity columns.
+func (p Policy) isIdentity(column string) bool {
+    for _, c := range p.identity() {
+        if c == column {
+            return true
+        }
+    }
+    return false
+}
+
+func (p Policy) validate() error {
+    if p.Table == "" || p.Key == "" {
+        return fmt.Errorf("sync policy needs a table and a key")
+    }
+    have := make(map[string]bool)
+    for _, c := range p.Columns {
+        have[c] = true
+    }
+    need := append([]string{p.Key}, p.Match...)
+    if p.Resolve != InsertOnly {
+        if p.By == "" {
+            return fmt.Errorf("sync policy for %s: needs a By column to resolve conflicts", p.Table)
+        }
+        need = append(need, p.By)
+    }
+    for _, f := range p.Filters {
+        need = append(need, f.Column)
+    }
+    for _, c := range need {
+        if !have[c] {
+            return fmt.Errorf("sync policy for %s: %s isn't one of the columns", p.Table, c)
+        }
+    }
+    return nil
+}
+
+// Run copies p.Table from src to dst according to p, recording every
+// change in opts.Audit. Source rows are read a page at a time in the
+// order of their Key and Match columns and each page is written in its
+// own transaction together with the checkpoint, so a sync that is
+// stopped, or hits MaxChanges, picks up after the last row it wrote. On a
+// dry run every page is rolled back, so the audit report shows what would
+// have happened.
+func Run(src, dst *sql.DB, p Policy, opts Options) error {
+    if err := p.validate(); err != nil {
+        return err
+    }
+    run := opts.Audit
+
+    after, err := checkpoint(dst, p.Name)
+    if err != nil {
+        return fmt.Errorf("failed to read checkpoint for %s: %w", p.Name, err)
+    }
+    if after != nil {
+        log.Printf("Resuming %s after %s", p.Name, p.position(after))
+    }
+
+    changes, read := 0, 0
+    for {
+        rows, err := fetch(src, p, after)
+        if err != nil {
+            return err
+        }
+        read += len(rows)
+
+        last, capped, err := syncBatch(dst, p, rows, run, &changes, opts.DryRun)
+        if err != nil {
+            return err
+        }
+        if last != nil {
+            after = last
+        }
+        if capped {
+            log.Printf("Reached the maximum number of changes: %d, next run resumes after %s", p.MaxChanges, p.position(after))
+            break
+        }
+        if len(rows) < p.batchSize() {
+            // Through the whole table, so the next run starts over
+            if !opts.DryRun {
+                if err := clearCheckpoint(dst, p.Name); err != nil {
+                    return fmt.Errorf("failed to clear checkpoint for %s: %w", p.Name, err)
+                }
+            }
+            break
+        }
+    }
+    log.Printf("Read %d source rows from %s and made %d changes", read, p.Table, changes)
+    if opts.DryRun {
+        log.Printf("Dry run: every change to %s was rolled back", p.Table)
+    }
+    return nil
+}
+
+// syncBatch writes one page of source rows and moves the checkpoint to
+// the last one written. It stops early once *changes reaches
+// p.MaxChanges and reports that it did.
+func syncBatch(dst *sql.DB, p Policy, rows []Row, run *audit.Run, changes *int, dryRun bool) ([]string, bool, error) {
+    if len(rows) == 0 {
+        return nil, false, nil
+    }
+    tx, err := dst.Begin()
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    identity := p.identity()
+    var where []string
+    for i, c := range identity {
+        where = append(where, fmt.Sprintf("%s = $%d", c, i+1))
+    }
+
+    list := strings.Join(p.Columns, ", ")
+    stmtCheck, err := tx.Prepare(fmt.Sprintf("SELECT %s FROM %s WHERE %s", list, p.Table, strings.Join(where, " AND ")))
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to prepare check statement: %w", err)
+    }
+    defer stmtCheck.Close()
+
+    stmtExists, err := tx.Prepare(fmt.Sprintf("SELECT 1 FROM %s WHERE %s = $1 LIMIT 1", p.Table, p.Key))
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to prepare exists statement: %w", err)
+    }
+    defer stmtExists.Close()
+
+    stmtInsert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", p.Table, list, placeholders(1, len(p.Columns))))
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to prepare insert statement: %w", err)
+    }
+    defer stmtInsert.Close()
+
+    // The identity columns come first, as in the check
+    var sets []string
+    for _, c := range p.Columns {
+        if !p.isIdentity(c) {
+            sets = append(sets, fmt.Sprintf("%s = $%d", c, len(identity)+len(sets)+1))
+        }
+    }
+    stmtUpdate, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET %s WHERE %s", p.Table, strings.Join(sets, ", "), strings.Join(where, " AND ")))
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to prepare update statement: %w", err)
+    }
+    defer stmtUpdate.Close()
+
+    var last []string
+    capped := false
+    for _, row := range rows {
+        if p.MaxChanges > 0 && *changes >= p.MaxChanges {
+            capped = true
+            break
+        }
+        key := row[p.Key]
+        ids := row.values(identity)
+        last = make([]string, len(ids))
+        for i, id := range ids {
+            last[i] = fmt.Sprint(id)
+        }
+
+        dest, err := scanRow(stmtCheck.QueryRow(ids...), p.Columns)
+        if err == sql.ErrNoRows {
+            // Another place already has this key
+            if len(p.Match) > 0 {
+                var one int
+                err := stmtExists.QueryRow(key).Scan(&one)
+                if err == nil {
+                    continue
+                }
+                if err != sql.ErrNoRows {
+                    return nil, false, fmt.Errorf("failed to check for existing %s=%v: %w", p.Key, key, err)
+                }
+            }
+            if _, err := stmtInsert.Exec(row.values(p.Columns)...); err != nil {
+                return nil, false, fmt.Errorf("failed to insert %s=%v: %w", p.Key, key, err)
+            }
+            change := audit.Change{Table: p.Table, Op: "inserted", Key: key, After: row}
+            run.Record(change)
+            log.Printf("Inserted %s %s", p.Table, describe(change))
+            *changes++
+            continue
+        }
+        if err != nil {
+            return nil, false, fmt.Errorf("failed to check for existing %s=%v: %w", p.Key, key, err)
+        }
+
+        if !p.wins(row, dest) {
+            continue
+        }
+        args := ids
+        for _, c := range p.Columns {
+            if !p.isIdentity(c) {
+                args = append(args, row[c])
+            }
+        }
+        if _, err := stmtUpdate.Exec(args...); err != nil {
+            return nil, false, fmt.Errorf("failed to update %s=%v: %w", p.Key, key, err)
+        }
+        change := audit.Change{Table: p.Table, Op: "updated", Key: key, Before: dest, After: row}
+        run.Record(change)
+        log.Printf("Updated %s %s", p.Table, describe(change))
+        *changes++
+
+        if p.AfterUpdate != nil {
+            if err := p.AfterUpdate(tx, change, run); err != nil {
+                return nil, false, err
+            }
+        }
+    }
+    if last == nil {
+        return nil, capped, nil
+    }
+
+    if err := setCheckpoint(tx, p.Name, last); err != nil {
+        return nil, false, fmt.Errorf("failed to update checkpoint for %s: %w", p.Name, err)
+    }
+    if dryRun {
+        return last, capped, nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return nil, false, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+    }
+    return last, capped, nil
+}
+
+// describe lists a change's key and every column that changed, for the
+// log.
+func describe(c audit.Change) string {
+    row := c.After
+    if row == nil {
+        row = c.Before
+    }
+    names := make([]string, 0, len(row))
+    for name := range row {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    parts := []string{fmt.Sprintf("key=%v", c.Key)}
+    for _, name := range names {
+        switch {
+        case c.Before == nil:
+            parts = append(parts, fmt.Sprintf("%s=%v", name, c.After[name]))
+        case c.After == nil:
+            parts = append(parts, fmt.Sprintf("%s=%v", name, c.Before[name]))
+        case fmt.Sprint(c.Before[name]) != fmt.Sprint(c.After[name]):
+            parts = append(parts, fmt.Sprintf("%s=%v->%v", name, c.Before[name], c.After[name]))
+        }
+    }
+    return strings.Join(parts, " ")
+}
+
+// wins reports whether the source row should replace the destination
+// row for the same place.
+func (p Policy) wins(src, dest Row) bool {
+    switch p.Resolve {
+    case NewerWins, HigherWins:
+        if src[p.By] == nil {
+            return false
+        }
+        return dest[p.By] == nil || compare(src[p.By], dest[p.By]) > 0
+    default:
+        return false
+    }
+}
+
+// compare orders two values of the same column.
+func compare(a, b interface{}) int {
+    switch a := a.(type) {
+    case time.Time:
+        if b, ok := b.(time.Time); ok {
+            switch {
+            case a.After(b):
+                return 1
+            case a.Before(b):
+                return -1
+            }
+            return 0
+        }
+    case int64:
+        if b, ok := b.(int64); ok {
+            switch {
+            case a > b:
+                return 1
+            case a < b:
+                return -1
+            }
+            return 0
+        }
+    case float64:
+        if b, ok := b.(float64); ok {
+            switch {
+            case a > b:
+                return 1
+            case a < b:
+                return -1
+            }
+            return 0
+        }
+    }
+    return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// position describes where a sync got to, for the log.
+func (p Policy) position(after []string) string {
+    identity := p.identity()
+    parts := make([]string, len(after))
+    for i, v := range after {
+        parts[i] = fmt.Sprintf("%s=%s", identity[i], v)
+    }
+    return strings.Join(parts, " ")
+}
+
+// fetch reads the next page of source rows after the identity values
+// after, nil for the first page, in the order of the identity columns.
+func fetch(db *sql.DB, p Policy, after []string) ([]Row, error) {
+    identity := p.identity()
+    var where []string
+    for _, c := range identity {
+        where = append(where, c+" IS NOT NULL")
+    }
+    var args []interface{}
+    if after != nil {
+        if len(after) != len(identity) {
+            return nil, fmt.Errorf("checkpoint has %d values for the %d identity columns %s", len(after), len(identity), strings.Join(identity, ", "))
+        }
+        for _, v := range after {
+            args = append(args, v)
+        }
+        where = append(where, fmt.Sprintf("(%s) > (%s)", strings.Join(identity, ", "), placeholders(1, len(args))))
+    }
+    for _, f := range p.Filters {
+        args = append(args, f.Min)
+        where = append(where, fmt.Sprintf("%s >= $%d", f.Column, len(args)))
+    }
+    query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %d",
+        strings.Join(p.Columns, ", "), p.Table, strings.Join(where, " AND "), strings.Join(identity, ", "), p.batchSize())
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch %s from source: %w", p.Table, err)
+    }
+    defer rows.Close()
+
+    var data []Row
+    for rows.Next() {
+        row, err := scanRow(rows, p.Columns)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan row: %w", err)
+        }
+        data = append(data, row)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", err)
+    }
+    return data, nil
+}
+
+type scanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanRow(s scanner, columns []string) (Row, error) {
+    values := make([]interface{}, len(columns))
+    ptrs := make([]interface{}, len(columns))
+    for i := range values {
+        ptrs[i] = &values[i]
+    }
+    if err := s.Scan(ptrs...); err != nil {
+        return nil, err
+    }
+    row := make(Row, len(columns))
+    for i, c := range columns {
+        // Numeric and unknown types come back as bytes
+        if b, ok := values[i].([]byte); ok {
+            values[i] = string(b)
+        }
+        row[c] = values[i]
+    }
+    return row, nil
+}
+
+func placeholders(from, n int) string {
+    list := make([]string, n)
+    for i := range list {
+        list[i] = fmt.Sprintf("$%d", from+i)
+    }
+    return strings.Join(list, ", ")
+}
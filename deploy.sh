@@ -1,7 +1,9 @@
 #!/bin/bash
+go build -o hexes . || exit 1
+
 while true; do
   echo "Starting server..."
-  go run main.go &
+  ./hexes serve &
 
   SERVER_PID=$!
   echo "Server running with PID $SERVER_PID"
@@ -17,4 +19,4 @@ while true; do
   kill $SERVER_PID
   echo "Stopping GeoJSON generation process..."
   kill $GENERATE_PID
-done
\ No newline at end of file
+done
@@ -3,5 +3,7 @@
 # Navigate to the project directory (in Replit)
 cd $REPL_HOME
 
-# Run update_h3_level_7.go to update temperature data in 'h3_level_7.geojson'
-go run code/porto.go
\ No newline at end of file
+# Add weather to the level 7 cells around Porto and upload 'h3_level_7.geojson',
+# as pipelines/porto.json lays out. Rolling up and exporting the levels is
+# left to pipelines/full.json.
+./hexes pipeline pipelines/porto.json
@@ -0,0 +1,67 @@
+package geojson
+
+import (
+    "bytes"
+    "encoding/json"
+    "io/ioutil"
+
+    h3 "github.com/uber/h3-go/v3"
+)
+
+// Geometry is a GeoJSON polygon.
+type Geometry struct {
+    Type        string        `json:"type"`
+    Coordinates [][][]float64 `json:"coordinates"`
+}
+
+// Feature is one GeoJSON feature, in this repo always an H3 cell.
+type Feature struct {
+    Type       string                 `json:"type"`
+    Geometry   Geometry               `json:"geometry"`
+    Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is what the map layers are made of.
+type FeatureCollection struct {
+    Type     string    `json:"type"`
+    Features []Feature `json:"features"`
+}
+
+// CellPolygon is the outline of an H3 cell, closed by repeating the first
+// point, in GeoJSON's longitude, latitude order.
+func CellPolygon(h3cell string) Geometry {
+    boundary := h3.ToGeoBoundary(h3.FromString(h3cell))
+    coordinates := make([][]float64, len(boundary), len(boundary)+1)
+    for i, coord := range boundary {
+        coordinates[i] = []float64{coord.Longitude, coord.Latitude}
+    }
+    if len(coordinates) > 0 {
+        coordinates = append(coordinates, coordinates[0])
+    }
+    return Geometry{Type: "Polygon", Coordinates: [][][]float64{coordinates}}
+}
+
+// CellFeature is the feature for an H3 cell with the given properties.
+func CellFeature(h3cell string, properties map[string]interface{}) Feature {
+    return Feature{Type: "Feature", Geometry: CellPolygon(h3cell), Properties: properties}
+}
+
+// Collection wraps features in a FeatureCollection.
+func Collection(features []Feature) FeatureCollection {
+    if features == nil {
+        features = []Feature{}
+    }
+    return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// Write saves features to path as a FeatureCollection. HTML isn't
+// escaped, so condition names and emoji come out as written.
+func Write(path string, features []Feature) error {
+    var buf bytes.Buffer
+    encoder := json.NewEncoder(&buf)
+    encoder.SetEscapeHTML(false)
+    if err := encoder.Encode(Collection(features)); err != nil {
+        return err
+    }
+    return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
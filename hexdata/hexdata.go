@@ -0,0 +1,47 @@
+package hexdata
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "time"
+)
+
+// Cell is one H3 cell as the exporters write it and the weather jobs read
+// it back.
+type Cell struct {
+    H3Index   string     `json:"h3_index"`
+    Visits    int        `json:"visits,omitempty"`
+    Total     int        `json:"total,omitempty"` // the map export writes the visit count as total
+    LastVisit *time.Time `json:"last_visit,omitempty"`
+    Activity  float64    `json:"activity,omitempty"` // as of the export
+}
+
+// Count returns the visit count whichever exporter wrote the cell.
+func (c Cell) Count() int {
+    if c.Visits > 0 {
+        return c.Visits
+    }
+    return c.Total
+}
+
+// Read loads the cells in path.
+func Read(path string) ([]Cell, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var cells []Cell
+    if err := json.Unmarshal(data, &cells); err != nil {
+        return nil, err
+    }
+    return cells, nil
+}
+
+// Write saves cells to path.
+func Write(path string, cells []Cell) error {
+    data, err := json.MarshalIndent(cells, "", "  ")
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(path, data, 0644)
+}
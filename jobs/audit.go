@@ -0,0 +1,124 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "sort"
+    "strconv"
+    "time"
+
+    "main/audit"
+    "main/config"
+)
+
+func runDuration(info audit.RunInfo) string {
+    if info.FinishedAt == nil {
+        return "-"
+    }
+    return info.FinishedAt.Sub(info.StartedAt).Round(time.Second).String()
+}
+
+func printRun(info audit.RunInfo) {
+    counts := info.CountsString()
+    if dryRun, _ := info.Params["dry_run"].(bool); dryRun {
+        counts = "dry run, would have made " + counts
+    }
+    fmt.Printf("%-6d %-14s %s  %-9s %-8s %s\n", info.ID, info.Job, info.StartedAt.Format("2006-01-02 15:04"), info.Status, runDuration(info), counts)
+    if info.Error != "" {
+        fmt.Printf("       error: %s\n", info.Error)
+    }
+}
+
+// auditCommand reads the job runs in the -db database; the sync jobs log
+// to SUPA_URL.
+func auditCommand(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    job := fs.String("job", "", "only list runs of `name`")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        fs.Usage()
+        return flag.ErrHelp
+    }
+    command := fs.Arg(0)
+
+    limit := 20
+    var id int64
+    switch command {
+    case "runs":
+        if fs.NArg() > 1 {
+            n, err := strconv.Atoi(fs.Arg(1))
+            if err != nil || n < 1 {
+                return fmt.Errorf("invalid number of runs %q", fs.Arg(1))
+            }
+            limit = n
+        }
+    case "show":
+        if fs.NArg() < 2 {
+            fs.Usage()
+            return flag.ErrHelp
+        }
+        n, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+        if err != nil {
+            return fmt.Errorf("invalid run id %q", fs.Arg(1))
+        }
+        id = n
+    default:
+        fs.Usage()
+        return flag.ErrHelp
+    }
+
+    db, err := config.DB(*dbEnv)
+    if err != nil {
+        return fmt.Errorf("failed to connect to database: %w", err)
+    }
+    defer db.Close()
+
+    if command == "runs" {
+        runs, err := audit.Recent(db, *job, limit)
+        if err != nil {
+            return fmt.Errorf("failed to list runs: %w", err)
+        }
+        for _, info := range runs {
+            printRun(info)
+        }
+        return nil
+    }
+
+    found, err := audit.Get(db, id)
+    if err != nil {
+        return fmt.Errorf("failed to read run %d: %w", id, err)
+    }
+    if found == nil {
+        return fmt.Errorf("no run %d in %s", id, *dbEnv)
+    }
+    printRun(*found)
+    fmt.Printf("       source: %s, dest: %s\n", found.Source, found.Dest)
+    names := make([]string, 0, len(found.Params))
+    for name := range found.Params {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        fmt.Printf("       %s = %v\n", name, found.Params[name])
+    }
+
+    changes, err := audit.Changes(db, id)
+    if err != nil {
+        return fmt.Errorf("failed to read changes: %w", err)
+    }
+    if len(changes) == 0 {
+        if dryRun, _ := found.Params["dry_run"].(bool); dryRun {
+            fmt.Println("No rows logged for a dry run; use -report to see them.")
+        } else {
+            fmt.Println("No rows logged; run with AUDIT_ROWS=true to log them.")
+        }
+        return nil
+    }
+    for _, c := range changes {
+        fmt.Printf("%-8s %s %v: %v -> %v\n", c.Op, c.Table, c.Key, c.Before, c.After)
+    }
+    return nil
+}
@@ -0,0 +1,255 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "time"
+
+    "main/hexdata"
+    "main/region"
+    "main/rollup"
+)
+
+// ExportDir is where the map export writes the levels.
+const ExportDir = "http/export"
+
+// fetchCells reads every cell of table. withActivity also reads
+// last_visit and the activity score, aged to now with halfLife.
+func fetchCells(db *sql.DB, table string, withActivity bool, halfLife time.Duration) ([]hexdata.Cell, error) {
+    query := fmt.Sprintf("SELECT h3_index, visits, last_visit, NULL::double precision, NULL::timestamptz FROM %s", table)
+    if withActivity {
+        query = fmt.Sprintf("SELECT h3_index, visits, last_visit, activity, activity_at FROM %s", table)
+    }
+    rows, err := db.Query(query)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var cells []hexdata.Cell
+    for rows.Next() {
+        var cell hexdata.Cell
+        var lastVisit sql.NullTime
+        var activity sql.NullFloat64
+        var activityAt sql.NullTime
+        if err := rows.Scan(&cell.H3Index, &cell.Visits, &lastVisit, &activity, &activityAt); err != nil {
+            return nil, err
+        }
+        if lastVisit.Valid {
+            t := lastVisit.Time
+            cell.LastVisit = &t
+        }
+        // Activity is stored as of the last rollup, so age it to now
+        if activity.Valid && activityAt.Valid {
+            cell.Activity = rollup.Score{Value: activity.Float64, At: activityAt.Time}.DecayTo(time.Now(), halfLife)
+        }
+        cells = append(cells, cell)
+    }
+    return cells, rows.Err()
+}
+
+// filterRegion keeps the cells whose center is inside r. Tables can hold
+// rows from before their region was narrowed, so exports check again.
+func filterRegion(cells []hexdata.Cell, r *region.Region) []hexdata.Cell {
+    if r == nil {
+        return cells
+    }
+    var kept []hexdata.Cell
+    for _, cell := range cells {
+        if r.ContainsCell(cell.H3Index) {
+            kept = append(kept, cell)
+        }
+    }
+    if dropped := len(cells) - len(kept); dropped > 0 {
+        log.Printf("Dropped %d cells outside %s", dropped, r.Name)
+    }
+    return kept
+}
+
+// windowVisits swaps each cell's all-time visits for its visits within the
+// window, dropping cells with none.
+func windowVisits(db *sql.DB, cells []hexdata.Cell, level int, w rollup.Window) ([]hexdata.Cell, error) {
+    activity, err := rollup.Activity(db, level, w)
+    if err != nil {
+        return nil, err
+    }
+    var active []hexdata.Cell
+    for _, cell := range cells {
+        if visits := activity[cell.H3Index]; visits > 0 {
+            cell.Visits = int(visits)
+            active = append(active, cell)
+        }
+    }
+    return active, nil
+}
+
+func exportMap(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    out := fs.String("out", ExportDir, "`directory` to write h3_level_N.json to")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    if err := os.MkdirAll(*out, os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create export directory: %w", err)
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    regions, err := region.FromEnv()
+    if err != nil {
+        return fmt.Errorf("failed to load regions: %w", err)
+    }
+
+    halfLife, err := rollup.HalfLifeFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid activity half-life: %w", err)
+    }
+
+    // Export visits in a recent window instead of all-time counts
+    window, err := rollup.WindowFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid visits window: %w", err)
+    }
+    if window != nil {
+        log.Printf("Exporting visits in the last %s", window)
+    }
+
+    // Each export covers the same region as its table
+    for _, l := range mapLevels {
+        table := fmt.Sprintf("h3_level_%d", l.level)
+        r, err := regions.Get(l.region)
+        if err != nil {
+            return fmt.Errorf("failed to set up export for %s: %w", table, err)
+        }
+
+        cells, err := fetchCells(db, table, true, halfLife)
+        if err != nil {
+            return fmt.Errorf("failed to fetch data for %s: %w", table, err)
+        }
+        cells = filterRegion(cells, r)
+        if window != nil {
+            cells, err = windowVisits(db, cells, l.level, *window)
+            if err != nil {
+                return fmt.Errorf("failed to fetch visit history for %s: %w", table, err)
+            }
+        }
+
+        // The map reads the visit count as total
+        for i := range cells {
+            cells[i].Total, cells[i].Visits = cells[i].Visits, 0
+        }
+        if err := hexdata.Write(filepath.Join(*out, fmt.Sprintf("h3_level_%d.json", l.level)), cells); err != nil {
+            return fmt.Errorf("failed to save JSON file for %s: %w", table, err)
+        }
+        if err := rollup.ClearDirty(db, "data2json", table); err != nil {
+            return fmt.Errorf("failed to clear dirty layer for %s: %w", table, err)
+        }
+    }
+
+    log.Printf("Successfully processed all levels and saved to %s", *out)
+    return nil
+}
+
+// tableExists checks if a table exists in the database
+func tableExists(db *sql.DB, table string) (bool, error) {
+    var name sql.NullString
+    if err := db.QueryRow("SELECT to_regclass($1)::text", table).Scan(&name); err != nil {
+        return false, err
+    }
+    return name.Valid, nil
+}
+
+// saveComplete7 saves every located place that has been visited, by
+// h3l7, as complete_7.json
+func saveComplete7(db *sql.DB, dir string) error {
+    rows, err := db.Query("SELECT visits, h3l7, last_visit FROM cities_with_users WHERE latitude IS NOT NULL AND longitude IS NOT NULL")
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    var data []map[string]interface{}
+    for rows.Next() {
+        var visits sql.NullInt64
+        var h3l7 sql.NullString
+        var lastVisit sql.NullTime
+        if err := rows.Scan(&visits, &h3l7, &lastVisit); err != nil {
+            return err
+        }
+
+        // Skip rows where visits is 0 or NULL or last_visit is NULL
+        if visits.Valid && visits.Int64 > 0 && lastVisit.Valid {
+            data = append(data, map[string]interface{}{
+                "visits":     visits.Int64,
+                "h3l7":       h3l7.String,
+                "last_visit": lastVisit.Time,
+            })
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    file, err := json.MarshalIndent(data, "", "  ")
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(filepath.Join(dir, "complete_7.json"), file, 0644)
+}
+
+func exportSup(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "SUPA_URL")
+    out := fs.String("out", "output", "`directory` to write the levels and complete_7.json to")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    if err := os.MkdirAll(*out, os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create output directory: %w", err)
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    for level := 2; level <= 7; level++ {
+        table := fmt.Sprintf("h3_level_%d", level)
+        exists, err := tableExists(db, table)
+        if err != nil {
+            return fmt.Errorf("failed to check if table %s exists: %w", table, err)
+        }
+        if !exists {
+            log.Printf("Table %s does not exist, skipping...", table)
+            continue
+        }
+
+        cells, err := fetchCells(db, table, false, 0)
+        if err != nil {
+            return fmt.Errorf("failed to fetch data for %s: %w", table, err)
+        }
+        if err := hexdata.Write(filepath.Join(*out, fmt.Sprintf("h3_level_%d.json", level)), cells); err != nil {
+            return fmt.Errorf("failed to save JSON file for %s: %w", table, err)
+        }
+    }
+
+    if err := saveComplete7(db, *out); err != nil {
+        return fmt.Errorf("failed to fetch and save complete_7 data: %w", err)
+    }
+
+    log.Printf("Successfully processed all levels and saved to %s", *out)
+    return nil
+}
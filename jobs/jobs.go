@@ -0,0 +1,144 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "flag"
+    "fmt"
+    "io"
+    "strings"
+    "text/tabwriter"
+
+    "main/config"
+    "main/schema"
+)
+
+// Command is one job the CLI can run.
+type Command struct {
+    // Name is what follows the binary on the command line, one or two
+    // words such as "weather porto".
+    Name string
+    // Args describes what follows the flags, if anything.
+    Args    string
+    Summary string
+    // Run defines its flags on fs, parses args with it and does the job.
+    Run func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+// Commands is every job, in the order the help lists them.
+var Commands = []Command{
+    {Name: "sync goo2sup", Summary: "copy places visited since August 2024 from GOOG_URL to SUPA_URL and update h3_level_9", Run: syncCommand(goo2sup, "GOOG_URL", "SUPA_URL")},
+    {Name: "sync last_visit", Summary: "copy places visited since August 2024 from POSTGRES_URL to SUPA_URL", Run: syncCommand(lastVisit, "POSTGRES_URL", "SUPA_URL")},
+    {Name: "sync migrate", Summary: "copy places with more visits from GOOG_URL to SUPA_URL", Run: syncCommand(migrate, "GOOG_URL", "SUPA_URL")},
+    {Name: "sync move_rows", Summary: "copy places SUPA_URL doesn't have yet from POSTGRES_URL", Run: syncCommand(moveRows, "POSTGRES_URL", "SUPA_URL")},
+
+    {Name: "rollup", Summary: "roll cities_with_users up into h3_level_0 to h3_level_9", Run: rollupAll},
+    {Name: "rollup regions", Summary: "roll up levels 3 to 7, each within its region", Run: rollupRegions},
+    {Name: "rollup sup", Summary: "roll up one level of places visited more than once", Run: rollupSup},
+    {Name: "rollup level2", Summary: "roll up h3_level_2 from places visited more than once", Run: rollupLevel2},
+    {Name: "feed", Summary: "keep the level tables up to date as cities_with_users changes", Run: feed},
+
+    {Name: "export", Summary: "export h3_level_3 to h3_level_7 as JSON for the map", Run: exportMap},
+    {Name: "export sup", Summary: "export the level tables and complete_7.json from SUPA_URL", Run: exportSup},
+
+    {Name: "weather export", Summary: "add weather to the exported levels and upload them", Run: weatherExport},
+    {Name: "weather porto", Summary: "add weather to the level 7 cells around Porto and upload them", Run: weatherPorto},
+    {Name: "weather reports", Summary: "weather for h3_level_3 and its parents, in http/reports", Run: weatherReports},
+    {Name: "weather europe", Summary: "weather for h3_level_4 and its parents, in http/europe", Run: weatherEurope},
+    {Name: "weather emoji", Summary: "weather emoji for the level 2 cells in http/emoji", Run: weatherEmoji},
+    {Name: "weather users", Args: "[file...]", Summary: "weather for users' cells and their parents, in http/users", Run: weatherUsers},
+    {Name: "weather parents", Summary: "weather for the parent cell lists in http", Run: weatherParents},
+    {Name: "weather cells", Summary: "weather for the parents of the cells in http/h3cells.geojson", Run: weatherCells},
+    {Name: "weather visits", Summary: "store the weather at each cell's last visit", Run: weatherVisits},
+
+    {Name: "upload", Args: "<file>...", Summary: "upload files to the default bucket", Run: upload},
+    {Name: "serve", Summary: "serve the map and h3_level_7.geojson from the bucket", Run: serve},
+
+    {Name: "airports", Summary: "index the airports in http/users by level 6 cell", Run: airports},
+    {Name: "spanish", Summary: "count Spanish-speaking devices per level 4 cell into spanish_l4", Run: spanish},
+    {Name: "data2l7", Summary: "fill in the missing h3l7 of cities_with_users", Run: data2l7},
+    {Name: "populate9", Summary: "place each often visited place in a random level 9 cell", Run: populate9},
+
+    {Name: "schema", Args: "status | up | down [n]", Summary: "show, apply or revert the schema migrations", Run: schemaCommand},
+    {Name: "audit", Args: "runs [n] | show <id>", Summary: "list job runs or show one with the rows it changed", Run: auditCommand},
+}
+
+// Lookup finds the command args start with, preferring two-word names,
+// and returns the args after its name.
+func Lookup(args []string) (Command, []string, bool) {
+    for n := 2; n >= 1; n-- {
+        if len(args) < n {
+            continue
+        }
+        if c, ok := Find(strings.Join(args[:n], " ")); ok {
+            return c, args[n:], true
+        }
+    }
+    return Command{}, args, false
+}
+
+// Find returns the command called name.
+func Find(name string) (Command, bool) {
+    for _, c := range Commands {
+        if c.Name == name {
+            return c, true
+        }
+    }
+    return Command{}, false
+}
+
+// Execute runs c with args, which start with its flags.
+func (c Command) Execute(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "usage: hexes %s [flags] %s\n\n%s\n\nflags:\n", c.Name, c.Args, c.Summary)
+        fs.PrintDefaults()
+    }
+    return c.Run(ctx, fs, args)
+}
+
+// Usage lists every command.
+func Usage(w io.Writer) {
+    fmt.Fprintln(w, "usage: hexes [-env-file path] <command> [flags] [args]")
+    fmt.Fprintln(w)
+    fmt.Fprintln(w, "commands:")
+    tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+    for _, c := range Commands {
+        fmt.Fprintf(tw, "  %s %s\t%s\n", c.Name, c.Args, c.Summary)
+    }
+    tw.Flush()
+    fmt.Fprintln(w)
+    fmt.Fprintln(w, "Run hexes <command> -h for its flags. Database flags name the environment")
+    fmt.Fprintln(w, "variable holding the URL, never the URL itself.")
+}
+
+// dbFlag adds -db, the environment variable holding the database URL.
+func dbFlag(fs *flag.FlagSet, def string) *string {
+    return fs.String("db", def, "environment `variable` holding the database URL")
+}
+
+// writeFlags adds -dry-run and -report for jobs that write to a database.
+func writeFlags(fs *flag.FlagSet) (*bool, *string) {
+    return fs.Bool("dry-run", false, "roll every change back and only report it"),
+        fs.String("report", "", "write a JSON diff of the changes to `file`")
+}
+
+// syncFlags adds -source and -dest for jobs that copy between databases.
+func syncFlags(fs *flag.FlagSet, source, dest string) (*string, *string) {
+    return fs.String("source", source, "environment `variable` holding the source database URL"),
+        fs.String("dest", dest, "environment `variable` holding the destination database URL")
+}
+
+// openDB connects to the database in envVar and checks its schema is the
+// one this binary was built for.
+func openDB(envVar string) (*sql.DB, error) {
+    db, err := config.DB(envVar)
+    if err != nil {
+        return nil, fmt.Errorf("failed to connect to %s: %w", envVar, err)
+    }
+    if err := schema.Check(db); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("database schema check failed for %s: %w", envVar, err)
+    }
+    return db, nil
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "main/pipeline"
+)
+
+// DefaultPipeline is the pipeline the deployment runs.
+const DefaultPipeline = "pipelines/porto.json"
+
+// Registered here rather than in Commands, since its steps are looked up
+// in Commands.
+func init() {
+    Commands = append(Commands, Command{Name: "pipeline", Args: "[file]", Summary: "run the steps of a pipeline file in order, skipping unchanged ones", Run: runPipeline})
+}
+
+func runPipeline(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    parallel := fs.Int("parallel", 2, "most `steps` to run at once")
+    statePath := fs.String("state", "", "`file` remembering each step's inputs (default the pipeline file with .state.json)")
+    force := fs.Bool("force", false, "run every step, even those whose inputs are unchanged")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() > 1 {
+        fs.Usage()
+        return flag.ErrHelp
+    }
+    path := DefaultPipeline
+    if fs.NArg() == 1 {
+        path = fs.Arg(0)
+    }
+    if *statePath == "" {
+        *statePath = strings.TrimSuffix(path, ".json") + ".state.json"
+    }
+
+    p, err := pipeline.Load(path)
+    if err != nil {
+        return err
+    }
+    // Catch a mistyped command before anything runs
+    for _, s := range p.Steps {
+        c, _, ok := Lookup(s.Command)
+        if !ok || c.Name == "pipeline" {
+            return fmt.Errorf("step %s: unknown command %q", s.Name, strings.Join(s.Command, " "))
+        }
+    }
+
+    exec := func(ctx context.Context, args []string) error {
+        c, rest, _ := Lookup(args)
+        return c.Execute(ctx, rest)
+    }
+    results, err := p.Run(ctx, exec, pipeline.Options{Parallel: *parallel, State: *statePath, Force: *force})
+
+    tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    fmt.Fprintln(tw, "STEP\tSTATUS\tTIME\tERROR")
+    for _, r := range results {
+        errText := ""
+        if r.Err != nil {
+            errText = r.Err.Error()
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Step, r.Status, r.Duration.Round(time.Second), errText)
+    }
+    tw.Flush()
+    return err
+}
@@ -0,0 +1,387 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "math/rand"
+    "sort"
+    "strconv"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/audit"
+    "main/config"
+)
+
+func airports(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    in := fs.String("in", "http/users/airport_coordinates.json", "airport coordinates `file`")
+    out := fs.String("out", "http/users/airport_h3_level6.json", "`file` to write the cells to")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    data, err := ioutil.ReadFile(*in)
+    if err != nil {
+        return fmt.Errorf("failed to read airport coordinates file: %w", err)
+    }
+    var coords map[string]struct {
+        Latitude  string `json:"latitude"`
+        Longitude string `json:"longitude"`
+    }
+    if err := json.Unmarshal(data, &coords); err != nil {
+        return fmt.Errorf("failed to parse airport coordinates: %w", err)
+    }
+
+    // Sorted so the output is stable between runs
+    codes := make([]string, 0, len(coords))
+    for code := range coords {
+        codes = append(codes, code)
+    }
+    sort.Strings(codes)
+
+    type entry struct {
+        Index string `json:"index"`
+        Value string `json:"value"`
+    }
+    var entries []entry
+    for _, code := range codes {
+        lat, err := strconv.ParseFloat(coords[code].Latitude, 64)
+        if err != nil {
+            log.Printf("Failed to parse latitude for airport %s: %v", code, err)
+            continue
+        }
+        lon, err := strconv.ParseFloat(coords[code].Longitude, 64)
+        if err != nil {
+            log.Printf("Failed to parse longitude for airport %s: %v", code, err)
+            continue
+        }
+        index := h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: lat, Longitude: lon}, 6))
+        entries = append(entries, entry{Index: index, Value: code})
+    }
+
+    data, err = json.MarshalIndent(entries, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to marshal H3 entries: %w", err)
+    }
+    if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+        return fmt.Errorf("failed to write output file: %w", err)
+    }
+    log.Printf("H3 level 6 indexes for airports written to %s", *out)
+    return nil
+}
+
+// spanishLocations reads where devices set to Spanish are.
+func spanishLocations(db *sql.DB) ([]h3.GeoCoord, error) {
+    rows, err := db.Query("SELECT latitude, longitude FROM device_location WHERE language = 'es'")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var coords []h3.GeoCoord
+    for rows.Next() {
+        var c h3.GeoCoord
+        if err := rows.Scan(&c.Latitude, &c.Longitude); err != nil {
+            return nil, err
+        }
+        coords = append(coords, c)
+    }
+    return coords, rows.Err()
+}
+
+// replaceCounts empties table and writes the device count of each cell,
+// or on a dry run rolls that back.
+func replaceCounts(db *sql.DB, table string, counts map[string]int, run *audit.Run) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if run.Rows() {
+        if err := recordDeleted(tx, table, run); err != nil {
+            return fmt.Errorf("failed to read %s: %w", table, err)
+        }
+    }
+    result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+    if err != nil {
+        return err
+    }
+    if deleted, err := result.RowsAffected(); err == nil && !run.Rows() {
+        run.Add(table+" deleted", deleted)
+    }
+
+    stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (h3_index, devices) VALUES ($1, $2)", table))
+    if err != nil {
+        return err
+    }
+    defer stmt.Close()
+
+    for index, count := range counts {
+        if _, err := stmt.Exec(index, count); err != nil {
+            return err
+        }
+        run.Record(audit.Change{Table: table, Op: "inserted", Key: index, After: map[string]interface{}{"devices": count}})
+    }
+    if run.DryRun() {
+        return nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// recordDeleted records every row of table in run as deleted, before
+// replaceCounts empties it.
+func recordDeleted(tx *sql.Tx, table string, run *audit.Run) error {
+    rows, err := tx.Query(fmt.Sprintf("SELECT h3_index, devices FROM %s", table))
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var index string
+        var devices sql.NullInt64
+        if err := rows.Scan(&index, &devices); err != nil {
+            return err
+        }
+        run.Record(audit.Change{Table: table, Op: "deleted", Key: index, Before: map[string]interface{}{"devices": devices.Int64}})
+    }
+    return rows.Err()
+}
+
+func spanish(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    sourceEnv, destEnv := syncFlags(fs, "GOOG_URL", "SUPA_URL")
+    resolution := fs.Int("resolution", 4, "H3 `resolution` to count devices at")
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    table := fmt.Sprintf("spanish_l%d", *resolution)
+
+    sourceDB, err := config.DB(*sourceEnv)
+    if err != nil {
+        return fmt.Errorf("failed to connect to source database: %w", err)
+    }
+    defer sourceDB.Close()
+
+    destDB, err := openDB(*destEnv)
+    if err != nil {
+        return err
+    }
+    defer destDB.Close()
+
+    run, err := audit.Start(destDB, audit.Job{Name: "spanish", Source: *sourceEnv, Dest: *destEnv, Params: map[string]interface{}{"resolution": *resolution}, DryRun: *dryRun})
+    if err != nil {
+        return fmt.Errorf("failed to start audit log: %w", err)
+    }
+    run.ReportTo(*report)
+
+    coords, err := spanishLocations(sourceDB)
+    if err != nil {
+        return run.Done(fmt.Errorf("failed to fetch data from device_location: %w", err))
+    }
+    run.Add("device_location read", int64(len(coords)))
+
+    counts := make(map[string]int)
+    for _, c := range coords {
+        counts[h3.ToString(h3.FromGeo(c, *resolution))]++
+    }
+    if err := replaceCounts(destDB, table, counts, run); err != nil {
+        return run.Done(fmt.Errorf("failed to insert H3 counts: %w", err))
+    }
+    if err := run.Done(nil); err != nil {
+        return err
+    }
+    log.Printf("Successfully processed and inserted H3 level %d counts for Spanish device locations.", *resolution)
+    return nil
+}
+
+// fillH3l7 sets h3l7 on every located place that lacks it, or on a dry
+// run rolls that back.
+func fillH3l7(db *sql.DB, run *audit.Run) error {
+    rows, err := db.Query(`
+        SELECT id, latitude, longitude
+        FROM cities_with_users
+        WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND h3l7 IS NULL`)
+    if err != nil {
+        return err
+    }
+    type place struct {
+        id    int
+        coord h3.GeoCoord
+    }
+    var places []place
+    for rows.Next() {
+        var p place
+        if err := rows.Scan(&p.id, &p.coord.Latitude, &p.coord.Longitude); err != nil {
+            rows.Close()
+            return err
+        }
+        places = append(places, p)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for _, p := range places {
+        index := h3.ToString(h3.FromGeo(p.coord, 7))
+        if _, err := tx.Exec("UPDATE cities_with_users SET h3l7=$1 WHERE id=$2", index, p.id); err != nil {
+            return err
+        }
+        run.Record(audit.Change{Table: "cities_with_users", Op: "updated", Key: p.id, Before: map[string]interface{}{"h3l7": nil}, After: map[string]interface{}{"h3l7": index}})
+    }
+    if run.DryRun() {
+        return nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func data2l7(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "SUPA_URL")
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    run, err := audit.Start(db, audit.Job{Name: "data2l7", Source: *dbEnv, Dest: *dbEnv, Params: map[string]interface{}{"resolution": 7}, DryRun: *dryRun})
+    if err != nil {
+        return fmt.Errorf("failed to start audit log: %w", err)
+    }
+    run.ReportTo(*report)
+    if err := fillH3l7(db, run); err != nil {
+        return run.Done(fmt.Errorf("failed to update h3l7 column: %w", err))
+    }
+    if err := run.Done(nil); err != nil {
+        return err
+    }
+    log.Println("Successfully updated h3l7 column with H3 indices of level 7 for all relevant rows.")
+    return nil
+}
+
+// fillLevel9 puts each place visited at least minVisits times in a
+// random level 9 child of its h3l7, or on a dry run rolls that back.
+func fillLevel9(db *sql.DB, minVisits int, run *audit.Run) error {
+    rows, err := db.Query("SELECT h3l7, visits FROM cities_with_users WHERE visits >= $1 AND h3l7 IS NOT NULL", minVisits)
+    if err != nil {
+        return fmt.Errorf("failed to fetch data from database: %w", err)
+    }
+    type place struct {
+        h3l7   string
+        visits int
+    }
+    var places []place
+    for rows.Next() {
+        var p place
+        if err := rows.Scan(&p.h3l7, &p.visits); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to scan row: %w", err)
+        }
+        places = append(places, p)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    for _, p := range places {
+        index := h3.FromString(p.h3l7)
+        if index == 0 {
+            return fmt.Errorf("failed to convert h3l7 to H3Index: %s", p.h3l7)
+        }
+        children := h3.ToChildren(index, 9)
+        h3l9 := h3.ToString(children[rand.Intn(len(children))])
+
+        var before map[string]interface{}
+        var oldVisits sql.NullInt64
+        err := tx.QueryRow("SELECT visits FROM h3_level_9 WHERE h3_index = $1", h3l9).Scan(&oldVisits)
+        switch {
+        case err == nil:
+            before = map[string]interface{}{"visits": oldVisits.Int64}
+        case err != sql.ErrNoRows:
+            return fmt.Errorf("failed to read h3_level_9: %w", err)
+        }
+
+        _, err = tx.Exec(`
+            INSERT INTO h3_level_9 (h3_index, visits)
+            VALUES ($1, $2)
+            ON CONFLICT (h3_index) DO UPDATE SET visits = EXCLUDED.visits`, h3l9, p.visits)
+        if err != nil {
+            return fmt.Errorf("failed to insert into h3_level_9 table: %w", err)
+        }
+        op := "updated"
+        if before == nil {
+            op = "inserted"
+        }
+        run.Record(audit.Change{Table: "h3_level_9", Op: op, Key: h3l9, Before: before, After: map[string]interface{}{"visits": p.visits}})
+    }
+
+    if run.DryRun() {
+        return nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit transaction: %w", err)
+    }
+    return nil
+}
+
+func populate9(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "SUPA_URL")
+    minVisits := fs.Int("min-visits", 4, "only places with at least this many `visits`")
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    run, err := audit.Start(db, audit.Job{Name: "populate9", Source: *dbEnv, Dest: *dbEnv, Params: map[string]interface{}{"min_visits": *minVisits}, DryRun: *dryRun})
+    if err != nil {
+        return fmt.Errorf("failed to start audit log: %w", err)
+    }
+    run.ReportTo(*report)
+    if err := fillLevel9(db, *minVisits, run); err != nil {
+        return run.Done(fmt.Errorf("failed to insert H3 level 9 data: %w", err))
+    }
+    if err := run.Done(nil); err != nil {
+        return err
+    }
+    log.Println("Successfully inserted H3 level 9 data for all relevant rows.")
+    return nil
+}
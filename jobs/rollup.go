@@ -0,0 +1,274 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "flag"
+    "fmt"
+    "log"
+
+    "main/audit"
+    "main/config"
+    "main/region"
+    "main/rollup"
+)
+
+// levelRegion is a level table and the region it covers, "" for the whole
+// world.
+type levelRegion struct {
+    level  int
+    region string
+}
+
+// mapLevels are the tables behind the map, as the regions rollup builds
+// them and the export reads them.
+var mapLevels = []levelRegion{
+    {3, ""},
+    {4, "europe"},
+    {5, "iberia"},
+    {6, "atlantic"},
+    {7, "atlantic"},
+}
+
+// supLevels are the tables the sup rollup can build.
+var supLevels = []levelRegion{
+    {2, ""},
+    {3, "europe"},
+    {4, "europe"},
+    {5, "iberia"},
+    {7, "iberia_west"},
+}
+
+// allLevels are h3_level_0 to h3_level_9 over the whole world.
+func allLevels() []rollup.Level {
+    var levels []rollup.Level
+    for res := 0; res <= 9; res++ {
+        levels = append(levels, rollup.Level{Resolution: res})
+    }
+    return levels
+}
+
+// regionLevels looks up the region of each level.
+func regionLevels(levels []levelRegion) ([]rollup.Level, error) {
+    regions, err := region.FromEnv()
+    if err != nil {
+        return nil, fmt.Errorf("failed to load regions: %w", err)
+    }
+    var out []rollup.Level
+    for _, l := range levels {
+        r, err := regions.Get(l.region)
+        if err != nil {
+            return nil, fmt.Errorf("failed to set up level %d: %w", l.level, err)
+        }
+        out = append(out, rollup.Level{Resolution: l.level, Region: r})
+    }
+    return out, nil
+}
+
+// fullFlag adds -full, which rebuilds the tables instead of merging in
+// the places visited since the last run.
+func fullFlag(fs *flag.FlagSet) *bool {
+    return fs.Bool("full", rollup.FullFromEnv(), "rebuild the tables from every place (ROLLUP_FULL)")
+}
+
+// runRollup rolls levels up as an audited run of job, reporting the cells
+// it writes to report if that is set.
+func runRollup(db *sql.DB, dbEnv, job string, columns []rollup.Column, levels []rollup.Level, opts rollup.Options, report string, params map[string]interface{}) error {
+    params["full"] = opts.Full
+    run, err := audit.Start(db, audit.Job{Name: job, Source: dbEnv, Dest: dbEnv, Params: params, DryRun: opts.DryRun})
+    if err != nil {
+        return fmt.Errorf("failed to start audit log: %w", err)
+    }
+    run.ReportTo(report)
+    opts.Audit = run
+    if err := rollup.Run(db, columns, levels, opts); err != nil {
+        return run.Done(fmt.Errorf("failed to roll up: %w", err))
+    }
+    return run.Done(nil)
+}
+
+func rollupAll(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    full := fullFlag(fs)
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    columns, err := rollup.ColumnsFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid rollup columns: %w", err)
+    }
+    columns = append(columns, rollup.DetailColumns...)
+
+    if err := runRollup(db, *dbEnv, "rollup", columns, allLevels(), rollup.Options{Full: *full, DryRun: *dryRun}, *report, map[string]interface{}{}); err != nil {
+        return err
+    }
+    log.Println("Successfully rolled up all levels.")
+    return nil
+}
+
+func rollupRegions(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    full := fullFlag(fs)
+    dryRun, report := writeFlags(fs)
+    from := fs.Int("from", 3, "first `level` to roll up, 3 to 7")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if *from < 3 || *from > 7 {
+        return fmt.Errorf("-from %d should be between 3 and 7", *from)
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    columns, err := rollup.ColumnsFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid rollup columns: %w", err)
+    }
+    levels, err := regionLevels(mapLevels)
+    if err != nil {
+        return err
+    }
+    var selected []rollup.Level
+    for _, level := range levels {
+        if level.Resolution >= *from {
+            selected = append(selected, level)
+        }
+    }
+
+    if err := runRollup(db, *dbEnv, "data2data", columns, selected, rollup.Options{Full: *full, DryRun: *dryRun}, *report, map[string]interface{}{"start_level": *from}); err != nil {
+        return err
+    }
+    log.Println("Successfully aggregated and updated visits for selected levels.")
+    return nil
+}
+
+func rollupSup(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "SUPA_URL")
+    full := fullFlag(fs)
+    dryRun, report := writeFlags(fs)
+    levelFlag := fs.Int("level", 0, "`level` to roll up: 2, 3, 4, 5 or 7")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    var level *levelRegion
+    for i := range supLevels {
+        if supLevels[i].level == *levelFlag {
+            level = &supLevels[i]
+        }
+    }
+    if level == nil {
+        return fmt.Errorf("-level should be 2, 3, 4, 5 or 7")
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    columns, err := rollup.ColumnsFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid rollup columns: %w", err)
+    }
+    levels, err := regionLevels([]levelRegion{*level})
+    if err != nil {
+        return err
+    }
+
+    // Only places visited more than once
+    opts := rollup.Options{MinVisits: 2, Full: *full, DryRun: *dryRun}
+    if err := runRollup(db, *dbEnv, "data4sup", columns, levels, opts, *report, map[string]interface{}{"level": level.level, "min_visits": opts.MinVisits}); err != nil {
+        return err
+    }
+    log.Printf("Successfully processed level %d.", level.level)
+    return nil
+}
+
+func rollupLevel2(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "SUPA_URL")
+    full := fullFlag(fs)
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    columns, err := rollup.ColumnsFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid rollup columns: %w", err)
+    }
+
+    // Only places visited more than once
+    opts := rollup.Options{MinVisits: 2, Full: *full, DryRun: *dryRun}
+    if err := runRollup(db, *dbEnv, "data2level2", columns, []rollup.Level{{Resolution: 2}}, opts, *report, map[string]interface{}{"min_visits": opts.MinVisits}); err != nil {
+        return err
+    }
+    log.Println("Successfully aggregated and updated visits for level 2.")
+    return nil
+}
+
+// feed needs migration 0010, which adds the trigger it listens to. To try
+// it against a local Postgres:
+//
+//     POSTGRES_URL=postgres://localhost/hexes?sslmode=disable hexes schema up
+//     POSTGRES_URL=postgres://localhost/hexes?sslmode=disable hexes feed
+//
+// then insert into or update cities_with_users from psql and watch the
+// cells and dirty_layers change.
+func feed(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    opts, err := rollup.FeedOptionsFromEnv()
+    if err != nil {
+        return err
+    }
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    full := fullFlag(fs)
+    fs.DurationVar(&opts.Debounce, "debounce", opts.Debounce, "wait this long after a change before rolling up (FEED_DEBOUNCE)")
+    fs.DurationVar(&opts.Poll, "poll", opts.Poll, "roll up this often even without changes, 0 never (FEED_POLL)")
+    fs.BoolVar(&opts.DryRun, "dry-run", false, "roll every rollup back and only report it; the watermarks stay put, so each one redoes the last")
+    fs.StringVar(&opts.Report, "report", "", "write a JSON diff of each rollup's changes to `file`, replacing the last")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    opts.Full = *full
+    opts.DBEnv = *dbEnv
+
+    dbURL, err := config.URL(*dbEnv)
+    if err != nil {
+        return err
+    }
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    columns, err := rollup.ColumnsFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid rollup columns: %w", err)
+    }
+    columns = append(columns, rollup.DetailColumns...)
+
+    if err := rollup.Follow(ctx, dbURL, db, columns, allLevels(), opts); err != nil {
+        return fmt.Errorf("change feed stopped: %w", err)
+    }
+    log.Println("Change feed stopped.")
+    return nil
+}
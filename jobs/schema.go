@@ -0,0 +1,79 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "strconv"
+
+    "main/config"
+    "main/schema"
+)
+
+func schemaCommand(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        fs.Usage()
+        return flag.ErrHelp
+    }
+    command := fs.Arg(0)
+
+    steps := 1
+    if command == "down" && fs.NArg() > 1 {
+        n, err := strconv.Atoi(fs.Arg(1))
+        if err != nil || n < 1 {
+            return fmt.Errorf("invalid number of migrations %q", fs.Arg(1))
+        }
+        steps = n
+    }
+
+    // The schema isn't checked here, since fixing it is the point
+    db, err := config.DB(*dbEnv)
+    if err != nil {
+        return fmt.Errorf("failed to connect to database: %w", err)
+    }
+    defer db.Close()
+
+    switch command {
+    case "status":
+        statuses, err := schema.StatusOf(db)
+        if err != nil {
+            return fmt.Errorf("failed to read schema status: %w", err)
+        }
+        for _, s := range statuses {
+            applied := "pending"
+            if s.AppliedAt != nil {
+                applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04")
+            }
+            fmt.Printf("%04d_%-24s %s\n", s.Version, s.Name, applied)
+        }
+
+    case "up":
+        done, err := schema.Up(db)
+        for _, m := range done {
+            log.Printf("Applied %04d_%s", m.Version, m.Name)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to migrate %s: %w", *dbEnv, err)
+        }
+        log.Printf("%s is up to date", *dbEnv)
+
+    case "down":
+        done, err := schema.Down(db, steps)
+        for _, m := range done {
+            log.Printf("Reverted %04d_%s", m.Version, m.Name)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to revert %s: %w", *dbEnv, err)
+        }
+
+    default:
+        fs.Usage()
+        return flag.ErrHelp
+    }
+    return nil
+}
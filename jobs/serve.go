@@ -0,0 +1,67 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+
+    "cloud.google.com/go/storage"
+
+    "main/objstore"
+)
+
+// bucketObject is the layer served from the bucket rather than from http.
+const bucketObject = "h3_level_7.geojson"
+
+func serve(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    addr := fs.String("addr", ":8080", "`address` to listen on")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    bucketName, err := objstore.DefaultBucket()
+    if err != nil {
+        return fmt.Errorf("failed to fetch default bucket ID: %w", err)
+    }
+    log.Printf("Fetched bucket ID: %s", bucketName)
+
+    client, err := storage.NewClient(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to create GCS client: %w", err)
+    }
+    defer client.Close()
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/"+bucketObject, func(w http.ResponseWriter, r *http.Request) {
+        rc, err := client.Bucket(bucketName).Object(bucketObject).NewReader(r.Context())
+        if err != nil {
+            http.Error(w, "Failed to read object", http.StatusInternalServerError)
+            log.Printf("Failed to read object: %v", err)
+            return
+        }
+        defer rc.Close()
+
+        w.Header().Set("Content-Type", "application/json")
+        if _, err := io.Copy(w, rc); err != nil {
+            log.Printf("Failed to copy data: %v", err)
+            return
+        }
+        log.Printf("Successfully served %s from bucket %s", bucketObject, bucketName)
+    })
+    // Serve the root as index.html
+    mux.Handle("/", http.FileServer(http.Dir("http")))
+
+    server := &http.Server{Addr: *addr, Handler: mux}
+    go func() {
+        <-ctx.Done()
+        server.Close()
+    }()
+    log.Printf("Listening on %s...", *addr)
+    if err := server.ListenAndServe(); err != http.ErrServerClosed {
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/audit"
+    "main/config"
+    "main/dbsync"
+)
+
+// syncColumns are the cities_with_users columns every sync copies.
+var syncColumns = []string{"city", "latitude", "longitude", "visits", "h3l7", "last_visit"}
+
+// syncCutoff is how far back the last_visit syncs look.
+var syncCutoff = time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+
+// goo2sup copies places visited since the cutoff whose source last_visit
+// is newer, as long as the city at that h3l7 is the same, and keeps
+// h3_level_9 in step with the places it updates.
+var goo2sup = dbsync.Policy{
+    Name:        "goo2sup",
+    Table:       "cities_with_users",
+    Key:         "h3l7",
+    Columns:     syncColumns,
+    Resolve:     dbsync.NewerWins,
+    By:          "last_visit",
+    Match:       []string{"city"},
+    Filters:     []dbsync.Filter{{Column: "last_visit", Min: syncCutoff}},
+    MaxChanges:  250,
+    AfterUpdate: updateLevel9,
+}
+
+// lastVisit copies places visited since the cutoff whose source
+// last_visit is newer than the same city's at that h3l7.
+var lastVisit = dbsync.Policy{
+    Name:       "last_visit",
+    Table:      "cities_with_users",
+    Key:        "h3l7",
+    Columns:    syncColumns,
+    Resolve:    dbsync.NewerWins,
+    By:         "last_visit",
+    Match:      []string{"city"},
+    Filters:    []dbsync.Filter{{Column: "last_visit", Min: syncCutoff}},
+    MaxChanges: 100,
+}
+
+// migrate copies places whose source has more visits than the same
+// city's at that h3l7.
+var migrate = dbsync.Policy{
+    Name:       "migrate",
+    Table:      "cities_with_users",
+    Key:        "h3l7",
+    Columns:    syncColumns,
+    Resolve:    dbsync.HigherWins,
+    By:         "visits",
+    Match:      []string{"city"},
+    MaxChanges: 100,
+}
+
+// moveRows only copies places at an h3l7 the destination doesn't have
+// yet.
+var moveRows = dbsync.Policy{
+    Name:    "move_rows",
+    Table:   "cities_with_users",
+    Key:     "h3l7",
+    Columns: syncColumns,
+    Resolve: dbsync.InsertOnly,
+    Match:   []string{"city"},
+}
+
+// updateLevel9 writes an updated place's visits and last_visit to its
+// h3_level_9 cell.
+func updateLevel9(tx *sql.Tx, change audit.Change, run *audit.Run) error {
+    lat, _ := change.After["latitude"].(float64)
+    lng, _ := change.After["longitude"].(float64)
+    h3Index := h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: lat, Longitude: lng}, 9))
+
+    visits, _ := change.After["visits"].(int64)
+    lastVisit := change.After["last_visit"]
+
+    var before map[string]interface{}
+    var oldVisits sql.NullInt64
+    var oldLastVisit sql.NullTime
+    err := tx.QueryRow("SELECT visits, last_visit FROM h3_level_9 WHERE h3_index=$1", h3Index).Scan(&oldVisits, &oldLastVisit)
+    switch {
+    case err == nil:
+        before = map[string]interface{}{"visits": oldVisits.Int64, "last_visit": oldLastVisit.Time}
+    case err != sql.ErrNoRows:
+        return fmt.Errorf("failed to read h3_level_9: %w", err)
+    }
+
+    if _, err := tx.Exec("INSERT INTO h3_level_9 (h3_index, visits, last_visit) VALUES ($1, $2, $3) ON CONFLICT (h3_index) DO UPDATE SET visits=$2, last_visit=$3", h3Index, visits, lastVisit); err != nil {
+        return fmt.Errorf("failed to execute insert statement for h3_level_9: %w", err)
+    }
+
+    op := "updated"
+    if before == nil {
+        op = "inserted"
+    }
+    run.Record(audit.Change{Table: "h3_level_9", Op: op, Key: h3Index, Before: before, After: map[string]interface{}{"visits": visits, "last_visit": lastVisit}})
+    log.Printf("Updated h3_level_9: h3_index=%s, visits=%d, last_visit=%v", h3Index, visits, lastVisit)
+    return nil
+}
+
+// syncCommand runs policy from source to dest, which are the defaults for
+// -source and -dest.
+func syncCommand(policy dbsync.Policy, source, dest string) func(context.Context, *flag.FlagSet, []string) error {
+    return func(ctx context.Context, fs *flag.FlagSet, args []string) error {
+        opts := dbsync.OptionsFromEnv()
+        sourceEnv, destEnv := syncFlags(fs, source, dest)
+        fs.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "roll every change back and only report it (SYNC_DRY_RUN)")
+        report := fs.String("report", os.Getenv("SYNC_REPORT"), "write a JSON diff of the changes to `file` (SYNC_REPORT)")
+        if err := fs.Parse(args); err != nil {
+            return err
+        }
+
+        sourceDB, err := config.DB(*sourceEnv)
+        if err != nil {
+            return fmt.Errorf("failed to connect to source database: %w", err)
+        }
+        defer sourceDB.Close()
+
+        destDB, err := openDB(*destEnv)
+        if err != nil {
+            return err
+        }
+        defer destDB.Close()
+
+        run, err := audit.Start(destDB, audit.Job{Name: policy.Name, Source: *sourceEnv, Dest: *destEnv, Params: map[string]interface{}{"max_changes": policy.MaxChanges}, DryRun: opts.DryRun})
+        if err != nil {
+            return fmt.Errorf("failed to start audit log: %w", err)
+        }
+        run.ReportTo(*report)
+        opts.Audit = run
+        if err := dbsync.Run(sourceDB, destDB, policy, opts); err != nil {
+            return run.Done(fmt.Errorf("failed to sync %s: %w", policy.Name, err))
+        }
+        if err := run.Done(nil); err != nil {
+            return err
+        }
+        log.Printf("Synced %s from %s to %s.", policy.Name, *sourceEnv, *destEnv)
+        return nil
+    }
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+
+    "main/objstore"
+)
+
+func upload(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        fs.Usage()
+        return flag.ErrHelp
+    }
+
+    bucket, err := objstore.Default()
+    if err != nil {
+        return err
+    }
+    for _, filename := range fs.Args() {
+        if err := bucket.Upload(filename); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "math"
+    "time"
+
+    "main/geojson"
+    "main/hexdata"
+    "main/weather"
+)
+
+// weatherJob holds what every weather layer is built with, all set up
+// from the environment.
+type weatherJob struct {
+    provider   weather.Provider
+    opts       weather.PoolOptions
+    cache      *weather.Cache
+    properties weather.PropertySet
+    // conditions names the weather code per WEATHER_LOCALES, or is nil
+    conditions *weather.Conditions
+    // alerts flags severe weather per WEATHER_ALERTS, or is nil
+    alerts *weather.Alerts
+}
+
+func newWeatherJob() (*weatherJob, error) {
+    provider, err := weather.FromEnv()
+    if err != nil {
+        return nil, fmt.Errorf("failed to set up weather provider: %w", err)
+    }
+    cache, err := weather.CacheFromEnv()
+    if err != nil {
+        return nil, fmt.Errorf("failed to open weather cache: %w", err)
+    }
+    w := &weatherJob{provider: provider, opts: weather.PoolOptionsFromEnv(), cache: cache}
+    w.opts.Cache = cache
+
+    if w.properties, err = weather.PropertiesFromEnv(); err != nil {
+        return nil, fmt.Errorf("failed to read weather properties: %w", err)
+    }
+    if w.conditions, err = weather.ConditionsFromEnv(); err != nil {
+        return nil, fmt.Errorf("failed to load weather conditions: %w", err)
+    }
+    if w.alerts, err = weather.AlertsFromEnv(); err != nil {
+        return nil, fmt.Errorf("failed to load alert rules: %w", err)
+    }
+    return w, nil
+}
+
+// finish logs how the run went and fails it if too many cells did.
+func (w *weatherJob) finish() error {
+    w.cache.LogStats()
+    w.opts.Stats.LogSummary()
+    if err := w.opts.Stats.Check(); err != nil {
+        return fmt.Errorf("weather run failed: %w", err)
+    }
+    return nil
+}
+
+// forecast returns the WEATHER_FORECAST steps and a provider for them, or
+// no steps if forecasts are off.
+func (w *weatherJob) forecast() (weather.ForecastProvider, []time.Duration, error) {
+    steps, err := weather.ForecastStepsFromEnv()
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to read forecast steps: %w", err)
+    }
+    if len(steps) == 0 {
+        return nil, nil, nil
+    }
+    forecaster, err := weather.AsForecast(w.provider)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to set up forecast: %w", err)
+    }
+    return forecaster, steps, nil
+}
+
+// feature is the cell with its weather status and the configured weather
+// properties and condition names.
+func (w *weatherJob) feature(h3cell string, result weather.Result) geojson.Feature {
+    properties := map[string]interface{}{
+        "h3cell":         h3cell,
+        "weather_status": result.Status(),
+    }
+    w.properties.Apply(properties, result)
+    w.conditions.Apply(properties, result)
+    return geojson.CellFeature(h3cell, properties)
+}
+
+// fetch gets the current weather of each cell, in order.
+func (w *weatherJob) fetch(ctx context.Context, cells []hexdata.Cell) ([]weather.Result, error) {
+    log.Printf("Fetching weather data for %d H3 cells", len(cells))
+    results := weather.FetchAll(ctx, w.provider, weather.CellPoints(cellIndexes(cells)), w.opts)
+    return results, ctx.Err()
+}
+
+// fetchForecast gets the forecast of each cell, one slice per step.
+func (w *weatherJob) fetchForecast(ctx context.Context, forecaster weather.ForecastProvider, steps []time.Duration, cells []hexdata.Cell) ([][]weather.Result, error) {
+    log.Printf("Fetching %d forecast steps for %d H3 cells", len(steps), len(cells))
+    results := weather.FetchForecast(ctx, forecaster, weather.CellPoints(cellIndexes(cells)), steps, w.opts)
+    return results, ctx.Err()
+}
+
+// layer makes one feature per cell with its weather, activity and alerts.
+// step is empty for current weather and the forecast offset (e.g. "24h")
+// otherwise. Failed cells stay on the map with a null temperature so they
+// can be told apart from real readings.
+func (w *weatherJob) layer(cells []hexdata.Cell, results []weather.Result, step string) []geojson.Feature {
+    features := make([]geojson.Feature, 0, len(cells))
+    for i, cell := range cells {
+        if err := results[i].Err; err != nil {
+            log.Printf("Failed to fetch weather data for cell %s: %v", cell.H3Index, err)
+        }
+        feature := w.feature(cell.H3Index, results[i])
+        feature.Properties["activity"] = math.Round(cell.Activity*100) / 100
+        w.alerts.Apply(feature.Properties, weather.AlertedCell{H3Index: cell.H3Index, Visits: cell.Count(), LastVisit: cell.LastVisit, ForecastStep: step}, results[i])
+        if step != "" {
+            feature.Properties["forecast_step"] = step
+        }
+        features = append(features, feature)
+    }
+    return features
+}
+
+func cellIndexes(cells []hexdata.Cell) []string {
+    indexes := make([]string, len(cells))
+    for i, cell := range cells {
+        indexes[i] = cell.H3Index
+    }
+    return indexes
+}
+
+// childWeather holds observations already fetched for a finer level so
+// coarser levels can be derived from them instead of fetched again.
+type childWeather struct {
+    cells   []string
+    samples []weather.Sample
+}
+
+// fetchParents gets the weather of cells at resolution, derived from
+// children if there are any and fetched otherwise. It also returns how
+// many children each cell was derived from.
+func (w *weatherJob) fetchParents(ctx context.Context, method weather.AggregateMethod, children *childWeather, cells []string, resolution int) ([]weather.Result, []int, error) {
+    var results []weather.Result
+    childCounts := make([]int, len(cells))
+    if children != nil {
+        results, childCounts = weather.ParentResults(ctx, w.provider, w.opts, cells, resolution, children.cells, children.samples, method)
+    } else {
+        log.Printf("Fetching weather data for %d cells at resolution %d", len(cells), resolution)
+        results = weather.FetchAll(ctx, w.provider, weather.CellPoints(cells), w.opts)
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, nil, fmt.Errorf("weather fetch cancelled: %w", err)
+    }
+    return results, childCounts, nil
+}
+
+// parentLayer makes one feature per cell, noting how derived cells were
+// aggregated.
+func (w *weatherJob) parentLayer(method weather.AggregateMethod, cells []string, results []weather.Result, childCounts []int) []geojson.Feature {
+    features := make([]geojson.Feature, 0, len(cells))
+    for i, cell := range cells {
+        // Failed cells get a null temperature rather than a fake 0
+        if err := results[i].Err; err != nil {
+            log.Printf("Failed to fetch temperature data for cell %s: %v", cell, err)
+        }
+        feature := w.feature(cell, results[i])
+        if childCounts[i] > 0 {
+            feature.Properties["aggregation"] = string(method)
+            feature.Properties["children"] = childCounts[i]
+        }
+        features = append(features, feature)
+    }
+    return features
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "path/filepath"
+    "strconv"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/geojson"
+    "main/hexdata"
+    "main/weather"
+)
+
+const (
+    emojiDir        = "http/emoji"
+    emojiInputFile  = "europe_h3_l2.json"
+    emojiOutputFile = "emoji.geojson"
+)
+
+// dayPhaseAt works out day, dawn, dusk or night from the sun's position
+// over the cell when the observation was taken, so it works for any
+// provider and doesn't depend on the icon
+func dayPhaseAt(h3cell string, obs weather.Observation) weather.Phase {
+    center := h3.ToGeo(h3.FromString(h3cell))
+    at := time.Now()
+    if obs.ObservedAt > 0 {
+        at = time.Unix(obs.ObservedAt, 0)
+    }
+    return weather.PhaseAt(center.Latitude, center.Longitude, at)
+}
+
+// emojiFeature is the cell with its temperature range and an emoji for
+// the weather at the time of day.
+func emojiFeature(w *weatherJob, codes *weather.Conditions, cell hexdata.Cell, result weather.Result) geojson.Feature {
+    feature := w.feature(cell.H3Index, result)
+    properties := feature.Properties
+    properties["temp_min"] = nil
+    properties["temp_max"] = nil
+    properties["weather_code"] = "unknown"
+
+    // A failed cell gets no emoji rather than the one for code 0
+    if result.Err == nil {
+        obs := result.Observation
+        code := strconv.Itoa(obs.Code)
+        properties["temp_min"] = obs.TempMin
+        properties["temp_max"] = obs.TempMax
+        properties["weather_code"] = code
+        phase := dayPhaseAt(cell.H3Index, obs)
+        properties["day_phase"] = phase
+        properties["emoji"] = codes.Emoji(obs.Code, phase)
+    }
+    w.alerts.Apply(properties, weather.AlertedCell{H3Index: cell.H3Index, Visits: cell.Count(), LastVisit: cell.LastVisit}, result)
+    return feature
+}
+
+func weatherEmoji(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    // The emoji come from the same catalogue as the condition names,
+    // which is only loaded for them when WEATHER_LOCALES is set
+    codes := w.conditions
+    if codes == nil {
+        if codes, err = weather.LoadConditions(weather.CodesFile(), nil); err != nil {
+            return err
+        }
+    }
+
+    cells, err := hexdata.Read(filepath.Join(emojiDir, emojiInputFile))
+    if err != nil {
+        return fmt.Errorf("failed to read H3 data file: %w", err)
+    }
+
+    results, err := w.fetch(ctx, cells)
+    if err != nil {
+        return err
+    }
+    features := make([]geojson.Feature, 0, len(cells))
+    for i, cell := range cells {
+        if err := results[i].Err; err != nil {
+            log.Printf("Failed to fetch weather data for cell %s: %v", cell.H3Index, err)
+        }
+        features = append(features, emojiFeature(w, codes, cell, results[i]))
+    }
+
+    geoJSONFile := filepath.Join(emojiDir, emojiOutputFile)
+    if err := geojson.Write(geoJSONFile, features); err != nil {
+        return fmt.Errorf("failed to write GeoJSON file with weather data: %w", err)
+    }
+    if err := w.alerts.WriteSummary(filepath.Join(emojiDir, "alerts.json")); err != nil {
+        return fmt.Errorf("failed to write alerts summary: %w", err)
+    }
+    if err := w.finish(); err != nil {
+        return err
+    }
+
+    log.Println("Successfully generated GeoJSON file with weather data:", geoJSONFile)
+    return nil
+}
@@ -0,0 +1,207 @@
+package jobs
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "path/filepath"
+
+    "main/geojson"
+    "main/hexdata"
+    "main/objstore"
+    "main/region"
+    "main/weather"
+)
+
+// portoRegion is the region in regions.json the Porto map covers.
+const portoRegion = "porto_metro"
+
+// WeatherDir is where the weather exports read the levels and write the
+// GeoJSON by default, the directory the deploy loop uploads from. It isn't
+// ExportDir, so point -dir there to add weather to a fresh export.
+const WeatherDir = "export"
+
+// publisher uploads the files a job writes, unless uploads are off.
+type publisher struct {
+    bucket *objstore.Bucket
+}
+
+func newPublisher(enabled bool) (*publisher, error) {
+    if !enabled {
+        return &publisher{}, nil
+    }
+    bucket, err := objstore.Default()
+    if err != nil {
+        return nil, err
+    }
+    return &publisher{bucket: bucket}, nil
+}
+
+func (p *publisher) upload(filename string) error {
+    if p.bucket == nil {
+        return nil
+    }
+    if err := p.bucket.Upload(filename); err != nil {
+        return fmt.Errorf("failed to upload %s to Object Storage: %w", filename, err)
+    }
+    return nil
+}
+
+func weatherExport(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dir := fs.String("dir", WeatherDir, "`directory` holding the exported h3_level_N.json, and where the GeoJSON goes")
+    upload := fs.Bool("upload", true, "upload the GeoJSON to the default bucket")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    // WEATHER_FORECAST adds forecast layers next to each level
+    forecaster, steps, err := w.forecast()
+    if err != nil {
+        return err
+    }
+    pub, err := newPublisher(*upload)
+    if err != nil {
+        return err
+    }
+
+    for level := 3; level <= 7; level++ {
+        cells, err := hexdata.Read(filepath.Join(*dir, fmt.Sprintf("h3_level_%d.json", level)))
+        if err != nil {
+            return fmt.Errorf("failed to read JSON file for level %d: %w", level, err)
+        }
+
+        results, err := w.fetch(ctx, cells)
+        if err != nil {
+            return err
+        }
+        geoJSONFile := filepath.Join(*dir, fmt.Sprintf("h3_level_%d.geojson", level))
+        if err := geojson.Write(geoJSONFile, w.layer(cells, results, "")); err != nil {
+            return fmt.Errorf("failed to write GeoJSON file for level %d: %w", level, err)
+        }
+
+        // Don't publish a map that is mostly failures
+        if err := w.opts.Stats.Check(); err != nil {
+            w.opts.Stats.LogSummary()
+            return fmt.Errorf("not uploading level %d: %w", level, err)
+        }
+        if err := pub.upload(geoJSONFile); err != nil {
+            return err
+        }
+
+        if forecaster == nil {
+            continue
+        }
+        forecasts, err := w.fetchForecast(ctx, forecaster, steps, cells)
+        if err != nil {
+            return err
+        }
+        var forecastFiles []string
+        for s, step := range steps {
+            label := weather.StepLabel(step)
+            forecastFile := filepath.Join(*dir, fmt.Sprintf("h3_level_%d_forecast_%s.geojson", level, label))
+            if err := geojson.Write(forecastFile, w.layer(cells, forecasts[s], label)); err != nil {
+                return fmt.Errorf("failed to write forecast GeoJSON file for level %d: %w", level, err)
+            }
+            forecastFiles = append(forecastFiles, forecastFile)
+        }
+        if err := w.opts.Stats.Check(); err != nil {
+            w.opts.Stats.LogSummary()
+            return fmt.Errorf("not uploading forecast for level %d: %w", level, err)
+        }
+        for _, forecastFile := range forecastFiles {
+            if err := pub.upload(forecastFile); err != nil {
+                return err
+            }
+        }
+    }
+
+    if w.alerts != nil {
+        alertsFile := filepath.Join(*dir, "alerts.json")
+        if err := w.alerts.WriteSummary(alertsFile); err != nil {
+            return fmt.Errorf("failed to write alerts summary: %w", err)
+        }
+        if err := pub.upload(alertsFile); err != nil {
+            return err
+        }
+    }
+
+    if err := w.finish(); err != nil {
+        return err
+    }
+    log.Print("Successfully processed all levels")
+    return nil
+}
+
+func weatherPorto(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dir := fs.String("dir", WeatherDir, "`directory` holding the exported h3_level_7.json, and where the GeoJSON goes")
+    upload := fs.Bool("upload", true, "upload the GeoJSON to the default bucket")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    pub, err := newPublisher(*upload)
+    if err != nil {
+        return err
+    }
+
+    // Only level 7 around Porto
+    cells, err := hexdata.Read(filepath.Join(*dir, "h3_level_7.json"))
+    if err != nil {
+        return fmt.Errorf("failed to read JSON file for level 7: %w", err)
+    }
+    regions, err := region.FromEnv()
+    if err != nil {
+        return fmt.Errorf("failed to load regions: %w", err)
+    }
+    porto, err := regions.Get(portoRegion)
+    if err != nil {
+        return fmt.Errorf("failed to find the Porto region: %w", err)
+    }
+    inside := porto.Cells(7)
+    var kept []hexdata.Cell
+    for _, cell := range cells {
+        if inside[cell.H3Index] {
+            kept = append(kept, cell)
+        }
+    }
+    log.Printf("Kept %d of %d cells inside %s", len(kept), len(cells), portoRegion)
+
+    results, err := w.fetch(ctx, kept)
+    if err != nil {
+        return err
+    }
+    geoJSONFile := filepath.Join(*dir, "h3_level_7.geojson")
+    if err := geojson.Write(geoJSONFile, w.layer(kept, results, "")); err != nil {
+        return fmt.Errorf("failed to write GeoJSON file for level 7: %w", err)
+    }
+
+    // Don't publish a map that is mostly failures
+    if err := w.finish(); err != nil {
+        return fmt.Errorf("not uploading level 7: %w", err)
+    }
+    if err := pub.upload(geoJSONFile); err != nil {
+        return err
+    }
+
+    if w.alerts != nil {
+        alertsFile := filepath.Join(*dir, "alerts_h3_level_7.json")
+        if err := w.alerts.WriteSummary(alertsFile); err != nil {
+            return fmt.Errorf("failed to write alerts summary: %w", err)
+        }
+        if err := pub.upload(alertsFile); err != nil {
+            return err
+        }
+    }
+
+    log.Print("Successfully processed level 7")
+    return nil
+}
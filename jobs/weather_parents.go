@@ -0,0 +1,150 @@
+package jobs
+
+import (
+    "context"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "log"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/geojson"
+    "main/weather"
+)
+
+// weatherParents writes the weather of the cells listed in
+// http/h3parents_levelN.json, finest level first so coarser levels can be
+// derived from it if WEATHER_AGGREGATE is set. The lists carry no visits
+// to weight the children by, so weighted_mean is refused.
+func weatherParents(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    method, err := weather.AggregateMethodFromEnv()
+    if err != nil {
+        return err
+    }
+    if method == weather.WeightedMean {
+        return fmt.Errorf("WEATHER_AGGREGATE=%s needs each cell's visits, which http/h3parents_levelN.json doesn't have; use %s or %s", method, weather.Mean, weather.Median)
+    }
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+
+    var children *childWeather
+    for level := 5; level >= 1; level-- {
+        inputFile := fmt.Sprintf("http/h3parents_level%d.json", level)
+        data, err := ioutil.ReadFile(inputFile)
+        if err != nil {
+            return fmt.Errorf("failed to read input JSON file (%s): %w", inputFile, err)
+        }
+        var lists map[string][]string
+        if err := json.Unmarshal(data, &lists); err != nil {
+            return fmt.Errorf("failed to parse input JSON file (%s): %w", inputFile, err)
+        }
+        cells := lists[fmt.Sprintf("h3cells_level%d", level)]
+
+        results, childCounts, err := w.fetchParents(ctx, method, children, cells, level)
+        if err != nil {
+            return err
+        }
+        outputFile := fmt.Sprintf("http/h3parents_level%d_weather.geojson", level)
+        if err := geojson.Write(outputFile, w.parentLayer(method, cells, results, childCounts)); err != nil {
+            return err
+        }
+        fmt.Printf("GeoJSON file created: %s\n", outputFile)
+
+        if method != "" && children == nil {
+            children = &childWeather{}
+            for i, result := range results {
+                if result.Err == nil {
+                    children.cells = append(children.cells, cells[i])
+                    children.samples = append(children.samples, weather.Sample{Observation: result.Observation, Weight: 1})
+                }
+            }
+        }
+    }
+
+    return w.finish()
+}
+
+// weatherCells writes the weather of the parents of the cells in
+// http/h3cells.geojson to http/h3parents.geojson. Deriving parents needs
+// the children's temperatures, which only the weather export carries, so
+// with WEATHER_AGGREGATE set it reads http/h3cells_weather.geojson.
+func weatherCells(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    method, err := weather.AggregateMethodFromEnv()
+    if err != nil {
+        return err
+    }
+
+    inputFile := "http/h3cells.geojson"
+    if method != "" {
+        inputFile = "http/h3cells_weather.geojson"
+    }
+    outputFile := "http/h3parents.geojson"
+
+    data, err := ioutil.ReadFile(inputFile)
+    if err != nil {
+        return fmt.Errorf("failed to read input GeoJSON file: %w", err)
+    }
+    var input geojson.FeatureCollection
+    if err := json.Unmarshal(data, &input); err != nil {
+        return fmt.Errorf("failed to parse input GeoJSON file: %w", err)
+    }
+
+    // Collect the distinct parent cells (resolution reduced by 1) in input
+    // order, and the children's own weather when parents are derived from
+    // them
+    var parents []string
+    var children *childWeather
+    if method != "" {
+        children = &childWeather{}
+    }
+    resolution := 0
+    seen := make(map[string]bool)
+    for _, feature := range input.Features {
+        h3cell, ok := feature.Properties["h3cell"].(string)
+        if !ok {
+            return fmt.Errorf("feature in %s has no h3cell", inputFile)
+        }
+        index := h3.FromString(h3cell)
+        resolution = h3.Resolution(index) - 1
+        parent := h3.ToString(h3.ToParent(index, resolution))
+        if !seen[parent] {
+            seen[parent] = true
+            parents = append(parents, parent)
+        }
+
+        if children != nil {
+            if obs, ok := weather.ObservationFromProperties(feature.Properties); ok {
+                visits, _ := feature.Properties["visits"].(float64)
+                children.cells = append(children.cells, h3cell)
+                children.samples = append(children.samples, weather.Sample{Observation: obs, Weight: visits})
+            }
+        }
+    }
+
+    results, childCounts, err := w.fetchParents(ctx, method, children, parents, resolution)
+    if err != nil {
+        return err
+    }
+    if err := geojson.Write(outputFile, w.parentLayer(method, parents, results, childCounts)); err != nil {
+        return err
+    }
+    log.Printf("GeoJSON file created: %s", outputFile)
+
+    return w.finish()
+}
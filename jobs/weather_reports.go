@@ -0,0 +1,172 @@
+package jobs
+
+import (
+    "bufio"
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/geojson"
+    "main/hexdata"
+    "main/rollup"
+    "main/weather"
+)
+
+// reportLayer is a level table whose cells get weather at their own
+// level and at every coarser level down to 1.
+type reportLayer struct {
+    // name is the layer in rollup.Layers
+    name   string
+    table  string
+    level  int
+    dir    string
+    prefix string
+}
+
+var (
+    reportsLayer = reportLayer{name: "pull_hexes", table: "h3_level_3", level: 3, dir: "http/reports", prefix: "reports"}
+    europeLayer  = reportLayer{name: "europe", table: "h3_level_4", level: 4, dir: "http/europe", prefix: "europe"}
+)
+
+func weatherReports(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    return weatherLayer(ctx, fs, args, reportsLayer)
+}
+
+func weatherEurope(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    return weatherLayer(ctx, fs, args, europeLayer)
+}
+
+// parentCells sums the cells into their parents at level, keeping the
+// latest visit. At the cells' own level it just sorts them.
+func parentCells(cells []hexdata.Cell, level, ownLevel int) []hexdata.Cell {
+    byIndex := make(map[string]*hexdata.Cell)
+    for _, cell := range cells {
+        parentIndex := cell.H3Index
+        if level < ownLevel {
+            parentIndex = h3.ToString(h3.ToParent(h3.FromString(cell.H3Index), level))
+        }
+        parent, ok := byIndex[parentIndex]
+        if !ok {
+            parent = &hexdata.Cell{H3Index: parentIndex}
+            byIndex[parentIndex] = parent
+        }
+        parent.Visits += cell.Count()
+        parent.Activity += cell.Activity
+        if cell.LastVisit != nil && (parent.LastVisit == nil || cell.LastVisit.After(*parent.LastVisit)) {
+            parent.LastVisit = cell.LastVisit
+        }
+    }
+
+    parents := make([]hexdata.Cell, 0, len(byIndex))
+    for _, parent := range byIndex {
+        parents = append(parents, *parent)
+    }
+    // Keep output files stable between runs
+    sort.Slice(parents, func(i, j int) bool {
+        return parents[i].H3Index < parents[j].H3Index
+    })
+    return parents
+}
+
+// confirm asks on stdin whether to go on.
+func confirm(question string) bool {
+    fmt.Printf("%s (yes/no): ", question)
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    return strings.TrimSpace(answer) == "yes"
+}
+
+func weatherLayer(ctx context.Context, fs *flag.FlagSet, args []string, l reportLayer) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    yes := fs.Bool("yes", false, "don't ask before fetching the weather")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    if err := os.MkdirAll(l.dir, os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create %s: %w", l.dir, err)
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    halfLife, err := rollup.HalfLifeFromEnv()
+    if err != nil {
+        return fmt.Errorf("invalid activity half-life: %w", err)
+    }
+    // WEATHER_FORECAST adds forecast layers next to each level
+    forecaster, steps, err := w.forecast()
+    if err != nil {
+        return err
+    }
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    cells, err := fetchCells(db, l.table, true, halfLife)
+    if err != nil {
+        return fmt.Errorf("failed to fetch data: %w", err)
+    }
+    fmt.Printf("There are %d unique H3 indexes in the %s table.\n", len(cells), l.table)
+    if !*yes && !confirm("Do you want to proceed with fetching the weather data?") {
+        log.Println("Aborted by user. Exiting...")
+        return nil
+    }
+
+    for level := l.level; level >= 1; level-- {
+        levelCells := parentCells(cells, level, l.level)
+        base := filepath.Join(l.dir, fmt.Sprintf("%s_h3_level_%d", l.prefix, level))
+        if err := hexdata.Write(base+".json", levelCells); err != nil {
+            return fmt.Errorf("failed to save intermediate JSON: %w", err)
+        }
+
+        results, err := w.fetch(ctx, levelCells)
+        if err != nil {
+            return err
+        }
+        if err := geojson.Write(base+".geojson", reportFeatures(w, levelCells, results, "")); err != nil {
+            return fmt.Errorf("failed to write GeoJSON file: %w", err)
+        }
+
+        if forecaster == nil {
+            continue
+        }
+        forecasts, err := w.fetchForecast(ctx, forecaster, steps, levelCells)
+        if err != nil {
+            return err
+        }
+        for s, step := range steps {
+            label := weather.StepLabel(step)
+            if err := geojson.Write(fmt.Sprintf("%s_forecast_%s.geojson", base, label), reportFeatures(w, levelCells, forecasts[s], label)); err != nil {
+                return fmt.Errorf("failed to write forecast GeoJSON file: %w", err)
+            }
+        }
+    }
+
+    if err := rollup.ClearDirty(db, l.name, l.table); err != nil {
+        return fmt.Errorf("failed to clear dirty layer: %w", err)
+    }
+    if err := w.alerts.WriteSummary(filepath.Join(l.dir, "alerts.json")); err != nil {
+        return fmt.Errorf("failed to write alerts summary: %w", err)
+    }
+    return w.finish()
+}
+
+// reportFeatures is the weather layer with each cell's visit count.
+func reportFeatures(w *weatherJob, cells []hexdata.Cell, results []weather.Result, step string) []geojson.Feature {
+    features := w.layer(cells, results, step)
+    for i, cell := range cells {
+        features[i].Properties["visits"] = cell.Visits
+    }
+    return features
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+    "context"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "path/filepath"
+    "sort"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/geojson"
+    "main/weather"
+)
+
+const usersDir = "http/users"
+
+// userCell is an entry of a users file, whose value is either a visit
+// count or a name.
+type userCell struct {
+    Index string
+    Value interface{}
+}
+
+// readUserCells reads a users file of {"index", "value"} entries.
+func readUserCells(path string) ([]userCell, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read input JSON file %s: %w", path, err)
+    }
+    var entries []struct {
+        Index string          `json:"index"`
+        Value json.RawMessage `json:"value"`
+    }
+    if err := json.Unmarshal(data, &entries); err != nil {
+        return nil, fmt.Errorf("failed to parse input JSON file %s: %w", path, err)
+    }
+
+    cells := make([]userCell, 0, len(entries))
+    for _, e := range entries {
+        var visits int
+        if err := json.Unmarshal(e.Value, &visits); err == nil {
+            cells = append(cells, userCell{Index: e.Index, Value: visits})
+            continue
+        }
+        var name string
+        if err := json.Unmarshal(e.Value, &name); err != nil {
+            return nil, fmt.Errorf("failed to parse input JSON file %s: value of %s is neither a count nor a name", path, e.Index)
+        }
+        cells = append(cells, userCell{Index: e.Index, Value: name})
+    }
+    return cells, nil
+}
+
+// groupUserCells groups the values of cells by their cell at resolution,
+// and lists those cells in order so the output is stable between runs.
+func groupUserCells(cells []userCell, resolution int) ([]string, map[string][]interface{}) {
+    values := make(map[string][]interface{})
+    for _, cell := range cells {
+        index := h3.FromString(cell.Index)
+        if h3.Resolution(index) > resolution {
+            index = h3.ToParent(index, resolution)
+        }
+        id := h3.ToString(index)
+        values[id] = append(values[id], cell.Value)
+    }
+    indexes := make([]string, 0, len(values))
+    for id := range values {
+        indexes = append(indexes, id)
+    }
+    sort.Strings(indexes)
+    return indexes, values
+}
+
+// weatherUsers writes the weather of the users' level 6 cells and of
+// their parents down to level 1, derived from level 6 if
+// WEATHER_AGGREGATE is set.
+func weatherUsers(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    files := fs.Args()
+    if len(files) == 0 {
+        files = []string{filepath.Join(usersDir, "4.json")}
+    }
+
+    w, err := newWeatherJob()
+    if err != nil {
+        return err
+    }
+    method, err := weather.AggregateMethodFromEnv()
+    if err != nil {
+        return err
+    }
+
+    var cells []userCell
+    for _, file := range files {
+        fileCells, err := readUserCells(file)
+        if err != nil {
+            return err
+        }
+        cells = append(cells, fileCells...)
+    }
+
+    var children *childWeather
+    for resolution := 6; resolution >= 1; resolution-- {
+        indexes, values := groupUserCells(cells, resolution)
+        results, childCounts, err := w.fetchParents(ctx, method, children, indexes, resolution)
+        if err != nil {
+            return err
+        }
+
+        outputFile := filepath.Join(usersDir, fmt.Sprintf("h3parent_level%d_weather.geojson", resolution))
+        if err := geojson.Write(outputFile, w.parentLayer(method, indexes, results, childCounts)); err != nil {
+            return err
+        }
+        fmt.Printf("GeoJSON file created: %s\n", outputFile)
+
+        // Level 6 first, so the parent levels can be derived from it
+        if method == "" || children != nil {
+            continue
+        }
+        children = &childWeather{}
+        for i, index := range indexes {
+            if results[i].Err != nil {
+                continue
+            }
+            // Integer values are visit counts; anything else counts once
+            weight := 0.0
+            for _, value := range values[index] {
+                if visits, ok := value.(int); ok {
+                    weight += float64(visits)
+                } else {
+                    weight++
+                }
+            }
+            children.cells = append(children.cells, index)
+            children.samples = append(children.samples, weather.Sample{Observation: results[i].Observation, Weight: weight})
+        }
+    }
+
+    if err := w.finish(); err != nil {
+        return err
+    }
+    log.Printf("Successfully wrote the weather for %d users' cells", len(cells))
+    return nil
+}
@@ -0,0 +1,209 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "log"
+    "time"
+
+    "main/audit"
+    "main/weather"
+)
+
+// defaultVisitUpdates is how many cells to backfill per table per run, to
+// keep paid historical lookups bounded.
+const defaultVisitUpdates = 500
+
+// A cell whose lookup failed waits visitRetryBase before it's tried again,
+// twice as long after each further failure in a row, up to visitRetryMax.
+const (
+    visitRetryBase = time.Hour
+    visitRetryMax  = 7 * 24 * time.Hour
+)
+
+// visitRetryDelay is how long a cell waits after its failures-th failed
+// lookup in a row.
+func visitRetryDelay(failures int) time.Duration {
+    delay := visitRetryBase
+    for i := 1; i < failures && delay < visitRetryMax; i++ {
+        delay *= 2
+    }
+    if delay > visitRetryMax {
+        delay = visitRetryMax
+    }
+    return delay
+}
+
+// dueCell is a cell whose last_visit has no weather stored yet.
+type dueCell struct {
+    H3Index   string
+    LastVisit time.Time
+    Failures  int
+}
+
+// fetchDueCells returns up to limit cells whose last_visit has no weather
+// stored yet, leaving out those backing off after a failure. Cells that
+// never failed come first, so a few that always do can't crowd out the
+// rest, then the most recently visited.
+func fetchDueCells(db *sql.DB, table string, limit int, now time.Time) ([]dueCell, error) {
+    rows, err := db.Query(fmt.Sprintf(`
+        SELECT h3_index, last_visit, last_visit_weather_failures FROM %s
+        WHERE last_visit IS NOT NULL AND last_visit_weather_at IS DISTINCT FROM last_visit
+          AND (last_visit_weather_retry_at IS NULL OR last_visit_weather_retry_at <= $2)
+        ORDER BY last_visit_weather_failures, last_visit DESC
+        LIMIT $1`, table), limit, now.UTC())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var cells []dueCell
+    for rows.Next() {
+        var cell dueCell
+        if err := rows.Scan(&cell.H3Index, &cell.LastVisit, &cell.Failures); err != nil {
+            return nil, err
+        }
+        cells = append(cells, cell)
+    }
+    return cells, rows.Err()
+}
+
+// storeVisitWeather writes the fetched weather next to each visit, or on
+// a dry run rolls that back. Failed cells are counted and put off for
+// visitRetryDelay, so the next runs try the others first.
+func storeVisitWeather(db *sql.DB, table string, cells []dueCell, results []weather.Result, now time.Time, run *audit.Run) (int, error) {
+    tx, err := db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    stmt, err := tx.Prepare(fmt.Sprintf(`
+        UPDATE %s SET last_visit_weather = $1, last_visit_weather_at = $2,
+            last_visit_weather_failures = 0, last_visit_weather_retry_at = NULL
+        WHERE h3_index = $3`, table))
+    if err != nil {
+        return 0, err
+    }
+    defer stmt.Close()
+    failStmt, err := tx.Prepare(fmt.Sprintf(`
+        UPDATE %s SET last_visit_weather_failures = $1, last_visit_weather_retry_at = $2
+        WHERE h3_index = $3`, table))
+    if err != nil {
+        return 0, err
+    }
+    defer failStmt.Close()
+
+    updated := 0
+    for i, cell := range cells {
+        if results[i].Err != nil {
+            failures := cell.Failures + 1
+            retryAt := now.UTC().Add(visitRetryDelay(failures))
+            log.Printf("Failed to fetch weather for %s at %s, %d times in a row, retrying after %s: %v",
+                cell.H3Index, cell.LastVisit.Format("2006-01-02 15:04"), failures, retryAt.Format("2006-01-02 15:04"), results[i].Err)
+            if _, err := failStmt.Exec(failures, retryAt, cell.H3Index); err != nil {
+                return 0, err
+            }
+            run.Add(table+" failed", 1)
+            continue
+        }
+        data, err := json.Marshal(results[i].Observation)
+        if err != nil {
+            return 0, err
+        }
+        if _, err := stmt.Exec(data, cell.LastVisit, cell.H3Index); err != nil {
+            return 0, err
+        }
+        run.Record(audit.Change{Table: table, Op: "updated", Key: cell.H3Index, After: map[string]interface{}{"last_visit_weather": results[i].Observation, "last_visit_weather_at": cell.LastVisit}})
+        updated++
+    }
+    if run.DryRun() {
+        return updated, nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return 0, err
+    }
+    return updated, tx.Commit()
+}
+
+// backfillVisits fetches and stores the weather for up to limit of the
+// table's due cells, and returns how many it stored.
+func backfillVisits(ctx context.Context, db *sql.DB, history weather.HistoricalProvider, table string, limit int, opts weather.PoolOptions, run *audit.Run) (int, error) {
+    now := time.Now()
+    cells, err := fetchDueCells(db, table, limit, now)
+    if err != nil {
+        return 0, fmt.Errorf("failed to fetch cells for %s: %w", table, err)
+    }
+    if len(cells) == 0 {
+        log.Printf("%s: nothing to backfill", table)
+        return 0, nil
+    }
+
+    log.Printf("%s: fetching weather at last visit for %d cells", table, len(cells))
+    indexes := make([]string, len(cells))
+    visits := make([]time.Time, len(cells))
+    for i, cell := range cells {
+        indexes[i] = cell.H3Index
+        visits[i] = cell.LastVisit
+    }
+    results := weather.FetchHistory(ctx, history, weather.CellPoints(indexes), visits, opts)
+    if err := ctx.Err(); err != nil {
+        return 0, fmt.Errorf("backfill cancelled: %w", err)
+    }
+
+    updated, err := storeVisitWeather(db, table, cells, results, now, run)
+    if err != nil {
+        return 0, fmt.Errorf("failed to store weather for %s: %w", table, err)
+    }
+    log.Printf("%s: stored weather for %d of %d cells", table, updated, len(cells))
+    return updated, nil
+}
+
+// weatherVisits backfills the weather at each cell's last visit.
+func weatherVisits(ctx context.Context, fs *flag.FlagSet, args []string) error {
+    dbEnv := dbFlag(fs, "POSTGRES_URL")
+    limit := fs.Int("max", defaultVisitUpdates, "most `cells` to backfill per table")
+    dryRun, report := writeFlags(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    provider, err := weather.FromEnv()
+    if err != nil {
+        return fmt.Errorf("failed to set up weather provider: %w", err)
+    }
+    history, err := weather.AsHistorical(provider)
+    if err != nil {
+        return fmt.Errorf("failed to set up historical weather: %w", err)
+    }
+    opts := weather.PoolOptionsFromEnv()
+
+    db, err := openDB(*dbEnv)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    tables := []string{"h3_level_3", "h3_level_4", "h3_level_5", "h3_level_6", "h3_level_7"}
+
+    run, err := audit.Start(db, audit.Job{Name: "visit_weather", Source: *dbEnv, Dest: *dbEnv, Params: map[string]interface{}{"tables": tables, "max_updates": *limit}, DryRun: *dryRun})
+    if err != nil {
+        return fmt.Errorf("failed to start audit log: %w", err)
+    }
+    run.ReportTo(*report)
+
+    for _, table := range tables {
+        if _, err := backfillVisits(ctx, db, history, table, *limit, opts, run); err != nil {
+            return run.Done(err)
+        }
+    }
+
+    opts.Stats.LogSummary()
+    if err := opts.Stats.Check(); err != nil {
+        return run.Done(fmt.Errorf("weather run failed: %w", err))
+    }
+    return run.Done(nil)
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "os"
+    "testing"
+    "time"
+
+    _ "github.com/lib/pq"
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/schema"
+    "main/weather"
+)
+
+func TestVisitRetryDelay(t *testing.T) {
+    tests := []struct {
+        failures int
+        want     time.Duration
+    }{
+        {1, time.Hour},
+        {2, 2 * time.Hour},
+        {4, 8 * time.Hour},
+        {8, 128 * time.Hour},
+        {9, visitRetryMax},
+        {100, visitRetryMax},
+    }
+    for _, tt := range tests {
+        if got := visitRetryDelay(tt.failures); got != tt.want {
+            t.Errorf("visitRetryDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+        }
+    }
+}
+
+// failingFake is the Fake provider, except it can never tell the weather
+// at the points in fail.
+type failingFake struct {
+    weather.Fake
+    fail map[weather.Point]bool
+}
+
+func (f failingFake) At(ctx context.Context, lat, lon float64, t time.Time) (weather.Observation, error) {
+    if f.fail[weather.Point{Lat: lat, Lon: lon}] {
+        return weather.Observation{}, errors.New("no weather here")
+    }
+    return f.Fake.At(ctx, lat, lon, t)
+}
+
+// testDB opens TEST_POSTGRES_URL and brings it up to the latest schema,
+// or skips the test without one. It must be a throwaway database.
+func testDB(t *testing.T) *sql.DB {
+    t.Helper()
+    if os.Getenv("TEST_POSTGRES_URL") == "" {
+        t.Skip("TEST_POSTGRES_URL not set")
+    }
+    db, err := sql.Open("postgres", os.Getenv("TEST_POSTGRES_URL"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+    // Owned by the app that records visits, so no migration creates it
+    _, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS cities_with_users (
+            id SERIAL PRIMARY KEY,
+            city TEXT,
+            latitude DOUBLE PRECISION,
+            longitude DOUBLE PRECISION,
+            visits INT,
+            last_visit TIMESTAMP
+        )`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := schema.Up(db); err != nil {
+        t.Fatal(err)
+    }
+    return db
+}
+
+func TestBackfillVisitsBacksOff(t *testing.T) {
+    db := testDB(t)
+    ctx := context.Background()
+
+    // A table of its own shaped like h3_level_3, so the test sees only
+    // its cells
+    const table = "visit_weather_test"
+    if _, err := db.Exec("CREATE TABLE " + table + " (LIKE h3_level_3 INCLUDING ALL)"); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Exec("DROP TABLE " + table) })
+
+    // The cell that always fails was visited last, so it used to come
+    // first every run
+    cells := []string{
+        h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: 41.15, Longitude: -8.61}, 3)),
+        h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: 40.42, Longitude: -3.70}, 3)),
+        h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: 48.86, Longitude: 2.35}, 3)),
+    }
+    visited := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+    for i, cell := range cells {
+        if _, err := db.Exec("INSERT INTO "+table+" (h3_index, last_visit) VALUES ($1, $2)", cell, visited.Add(-time.Duration(i)*time.Hour)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    provider := failingFake{fail: map[weather.Point]bool{weather.CellPoints(cells[:1])[0]: true}}
+    opts := weather.PoolOptions{Workers: 1, RequestsPerSecond: 100, Timeout: time.Second, Retry: weather.RetryPolicy{MaxAttempts: 1}}
+
+    // One cell a run: the failing one, then each of the others
+    for run, want := range []int{0, 1, 1, 0} {
+        updated, err := backfillVisits(ctx, db, provider, table, 1, opts, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if updated != want {
+            t.Fatalf("run %d stored %d cells, want %d", run+1, updated, want)
+        }
+    }
+    for _, cell := range cells[1:] {
+        var stored bool
+        if err := db.QueryRow("SELECT last_visit_weather IS NOT NULL FROM "+table+" WHERE h3_index = $1", cell).Scan(&stored); err != nil {
+            t.Fatal(err)
+        }
+        if !stored {
+            t.Errorf("%s has no weather", cell)
+        }
+    }
+
+    // Once its wait is over it is tried again, and waits longer
+    checkBackoff := func(wantFailures int) {
+        t.Helper()
+        var failures int
+        var retryAt time.Time
+        err := db.QueryRow("SELECT last_visit_weather_failures, last_visit_weather_retry_at FROM "+table+" WHERE h3_index = $1", cells[0]).Scan(&failures, &retryAt)
+        if err != nil {
+            t.Fatal(err)
+        }
+        wait := retryAt.Sub(time.Now().UTC())
+        want := visitRetryDelay(wantFailures)
+        if failures != wantFailures || wait < want-time.Minute || wait > want {
+            t.Errorf("got %d failures retrying in %s, want %d in %s", failures, wait.Round(time.Second), wantFailures, want)
+        }
+    }
+    checkBackoff(1)
+    if _, err := db.Exec("UPDATE "+table+" SET last_visit_weather_retry_at = $1 WHERE h3_index = $2", time.Now().UTC().Add(-time.Minute), cells[0]); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := backfillVisits(ctx, db, provider, table, 1, opts, nil); err != nil {
+        t.Fatal(err)
+    }
+    checkBackoff(2)
+}
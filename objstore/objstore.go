@@ -0,0 +1,103 @@
+package objstore
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "path/filepath"
+)
+
+// sidecar hands out the default bucket and access tokens for it.
+const sidecar = "http://127.0.0.1:1106"
+
+// DefaultBucket returns the ID of the bucket the maps are published to.
+func DefaultBucket() (string, error) {
+    resp, err := http.Get(sidecar + "/object-storage/default-bucket")
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        BucketID string `json:"bucketId"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return "", fmt.Errorf("failed to decode bucket response: %w", err)
+    }
+    if result.BucketID == "" {
+        return "", fmt.Errorf("fetched bucket ID is empty")
+    }
+    return result.BucketID, nil
+}
+
+// Token returns an access token for the bucket.
+func Token() (string, error) {
+    resp, err := http.Post(sidecar+"/token", "application/json", nil)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        AccessToken string `json:"access_token"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return "", fmt.Errorf("failed to decode token response: %w", err)
+    }
+    if result.AccessToken == "" {
+        return "", fmt.Errorf("fetched token is empty")
+    }
+    return result.AccessToken, nil
+}
+
+// Bucket uploads files to one bucket.
+type Bucket struct {
+    ID    string
+    token string
+}
+
+// Default returns the default bucket with a fresh token for it.
+func Default() (*Bucket, error) {
+    id, err := DefaultBucket()
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch default bucket ID: %w", err)
+    }
+    token, err := Token()
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch token: %w", err)
+    }
+    log.Printf("Fetched bucket ID: %s", id)
+    return &Bucket{ID: id, token: token}, nil
+}
+
+// Upload stores filename in the bucket under its base name.
+func (b *Bucket) Upload(filename string) error {
+    data, err := ioutil.ReadFile(filename)
+    if err != nil {
+        return err
+    }
+
+    url := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=media&name=%s", b.ID, filepath.Base(filename))
+    req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+b.token)
+    req.Header.Set("Content-Type", "application/octet-stream")
+
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        body, _ := ioutil.ReadAll(resp.Body)
+        return fmt.Errorf("failed to upload %s: %s", filepath.Base(filename), string(body))
+    }
+    log.Printf("Uploaded %s to %s", filepath.Base(filename), b.ID)
+    return nil
+}
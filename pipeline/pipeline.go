@@ -0,0 +1,144 @@
+// Package pipeline runs jobs as a graph of steps declared in a JSON file,
+// so the order they depend on each other in is written down rather than
+// kept in scripts.
+package pipeline
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "strings"
+    "time"
+)
+
+// Step is one job in a pipeline.
+type Step struct {
+    Name string `json:"name"`
+    // Command is the job and its arguments, as they would follow the
+    // binary on the command line.
+    Command []string `json:"command"`
+    // After lists the steps that must succeed before this one starts.
+    After []string `json:"after,omitempty"`
+    // Inputs and Outputs are file globs. A step with inputs is skipped
+    // when they and its command are unchanged since it last succeeded
+    // and its outputs are all there. A step without inputs always runs.
+    Inputs  []string `json:"inputs,omitempty"`
+    Outputs []string `json:"outputs,omitempty"`
+    // MaxAge, if set, runs the step again once its last success is this
+    // old even if its inputs are unchanged, for steps that fetch data
+    // which changes by itself, like the weather.
+    MaxAge Duration `json:"max_age,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "3h" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err != nil {
+        return fmt.Errorf("duration must be a string such as \"3h\": %w", err)
+    }
+    parsed, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+    *d = Duration(parsed)
+    return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+    return json.Marshal(time.Duration(d).String())
+}
+
+// Pipeline is the steps of a pipeline file.
+type Pipeline struct {
+    Steps []Step `json:"steps"`
+}
+
+// Load reads and checks a pipeline file.
+func Load(path string) (*Pipeline, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read pipeline file: %w", err)
+    }
+    var p Pipeline
+    if err := json.Unmarshal(data, &p); err != nil {
+        return nil, fmt.Errorf("failed to parse pipeline file %s: %w", path, err)
+    }
+    if err := p.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid pipeline file %s: %w", path, err)
+    }
+    return &p, nil
+}
+
+// Validate checks every step has a unique name and a command, and that
+// the steps they come after exist and never lead back to themselves.
+func (p *Pipeline) Validate() error {
+    if len(p.Steps) == 0 {
+        return fmt.Errorf("no steps")
+    }
+    steps := make(map[string]Step)
+    for _, s := range p.Steps {
+        if s.Name == "" {
+            return fmt.Errorf("step %q has no name", strings.Join(s.Command, " "))
+        }
+        if _, ok := steps[s.Name]; ok {
+            return fmt.Errorf("step %s is declared twice", s.Name)
+        }
+        if len(s.Command) == 0 {
+            return fmt.Errorf("step %s has no command", s.Name)
+        }
+        steps[s.Name] = s
+    }
+    for _, s := range p.Steps {
+        for _, dep := range s.After {
+            if _, ok := steps[dep]; !ok {
+                return fmt.Errorf("step %s comes after unknown step %s", s.Name, dep)
+            }
+        }
+    }
+
+    // Depth-first, keeping the path so a cycle can be shown
+    const (
+        visiting = 1
+        done     = 2
+    )
+    state := make(map[string]int)
+    var path []string
+    var visit func(name string) error
+    visit = func(name string) error {
+        switch state[name] {
+        case done:
+            return nil
+        case visiting:
+            return fmt.Errorf("steps depend on each other: %s -> %s", strings.Join(path, " -> "), name)
+        }
+        state[name] = visiting
+        path = append(path, name)
+        for _, dep := range steps[name].After {
+            if err := visit(dep); err != nil {
+                return err
+            }
+        }
+        path = path[:len(path)-1]
+        state[name] = done
+        return nil
+    }
+    for _, s := range p.Steps {
+        if err := visit(s.Name); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// dependents maps each step to the steps that come directly after it.
+func (p *Pipeline) dependents() map[string][]string {
+    next := make(map[string][]string)
+    for _, s := range p.Steps {
+        for _, dep := range s.After {
+            next[dep] = append(next[dep], s.Name)
+        }
+    }
+    return next
+}
@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+)
+
+// Status is how a step ended.
+type Status string
+
+const (
+    Succeeded Status = "succeeded"
+    Skipped   Status = "skipped" // unchanged since it last succeeded
+    Failed    Status = "failed"
+    Blocked   Status = "blocked"   // a step it comes after didn't succeed
+    Cancelled Status = "cancelled" // the run was interrupted before it started
+)
+
+// Result is how one step of a run went.
+type Result struct {
+    Step     string
+    Status   Status
+    Err      error
+    Duration time.Duration
+}
+
+func (r Result) ok() bool {
+    return r.Status == Succeeded || r.Status == Skipped
+}
+
+// Exec runs the command of a step.
+type Exec func(ctx context.Context, args []string) error
+
+// Options control a Run.
+type Options struct {
+    // Parallel is how many steps may run at once, 1 if 0.
+    Parallel int
+    // State is the file remembering which steps succeeded with which
+    // inputs. Without one no step is skipped.
+    State string
+    // Force runs every step, even those whose inputs are unchanged.
+    Force bool
+}
+
+// Run executes the steps with exec, each once the steps it comes after
+// have succeeded or been skipped, and as many at a time as
+// opts.Parallel allows. When a step fails the steps after it are
+// blocked, while the rest carry on. Results are in the order the steps
+// finished, and the error says which didn't succeed.
+func (p *Pipeline) Run(ctx context.Context, exec Exec, opts Options) ([]Result, error) {
+    st, err := loadState(opts.State)
+    if err != nil {
+        return nil, err
+    }
+    parallel := opts.Parallel
+    if parallel < 1 {
+        parallel = 1
+    }
+
+    steps := make(map[string]Step)
+    pending := make(map[string]int)
+    var ready []string
+    for _, s := range p.Steps {
+        steps[s.Name] = s
+        pending[s.Name] = len(s.After)
+        if len(s.After) == 0 {
+            ready = append(ready, s.Name)
+        }
+    }
+    next := p.dependents()
+
+    var results []Result
+    finished := make(map[string]bool)
+    // block marks everything after a step that didn't succeed
+    var block func(name, cause string)
+    block = func(name, cause string) {
+        for _, dep := range next[name] {
+            if finished[dep] {
+                continue
+            }
+            finished[dep] = true
+            results = append(results, Result{Step: dep, Status: Blocked, Err: fmt.Errorf("%s did not succeed", cause)})
+            log.Printf("Blocked %s: %s did not succeed", dep, cause)
+            block(dep, cause)
+        }
+    }
+
+    done := make(chan Result)
+    running := 0
+    for {
+        for len(ready) > 0 && running < parallel && ctx.Err() == nil {
+            name := ready[0]
+            ready = ready[1:]
+            running++
+            go func(s Step) {
+                done <- runStep(ctx, s, exec, st, opts.Force)
+            }(steps[name])
+        }
+        if running == 0 {
+            break
+        }
+
+        result := <-done
+        running--
+        finished[result.Step] = true
+        results = append(results, result)
+        if !result.ok() {
+            block(result.Step, result.Step)
+            continue
+        }
+        for _, dep := range next[result.Step] {
+            pending[dep]--
+            if pending[dep] == 0 && !finished[dep] {
+                ready = append(ready, dep)
+            }
+        }
+    }
+
+    // Interrupted: whatever hadn't started never will
+    for _, s := range p.Steps {
+        if !finished[s.Name] {
+            results = append(results, Result{Step: s.Name, Status: Cancelled, Err: ctx.Err()})
+        }
+    }
+
+    var failed []string
+    for _, r := range results {
+        if !r.ok() {
+            failed = append(failed, r.Step)
+        }
+    }
+    if len(failed) > 0 {
+        return results, fmt.Errorf("%d of %d steps did not succeed: %s", len(failed), len(p.Steps), strings.Join(failed, ", "))
+    }
+    return results, nil
+}
+
+// runStep runs s unless it can be skipped, and remembers its inputs if it
+// succeeds.
+func runStep(ctx context.Context, s Step, exec Exec, st *state, force bool) Result {
+    start := time.Now()
+    result := Result{Step: s.Name}
+    finish := func(status Status, err error) Result {
+        result.Status = status
+        result.Err = err
+        result.Duration = time.Since(start)
+        return result
+    }
+
+    fp, err := fingerprint(s)
+    if err != nil {
+        log.Printf("Failed %s: %v", s.Name, err)
+        return finish(Failed, err)
+    }
+    if reason, ok := skipReason(s, fp, st, force); ok {
+        log.Printf("Skipping %s: %s", s.Name, reason)
+        return finish(Skipped, nil)
+    }
+
+    log.Printf("Running %s: %s", s.Name, strings.Join(s.Command, " "))
+    if err := exec(ctx, s.Command); err != nil {
+        log.Printf("Failed %s after %s: %v", s.Name, time.Since(start).Round(time.Second), err)
+        return finish(Failed, err)
+    }
+    // The inputs as they were when it started, so a change made while it
+    // ran isn't mistaken for one it has seen
+    if err := st.record(s.Name, fp); err != nil {
+        log.Printf("Failed to save pipeline state after %s: %v", s.Name, err)
+    }
+    log.Printf("Finished %s in %s", s.Name, time.Since(start).Round(time.Second))
+    return finish(Succeeded, nil)
+}
+
+// skipReason says why s needn't run, if it needn't.
+func skipReason(s Step, fp string, st *state, force bool) (string, bool) {
+    if force || len(s.Inputs) == 0 {
+        return "", false
+    }
+    last, ok := st.get(s.Name)
+    if !ok || last.Fingerprint != fp {
+        return "", false
+    }
+    if s.MaxAge > 0 && time.Since(last.Succeeded) >= time.Duration(s.MaxAge) {
+        return "", false
+    }
+    if ok, missing := outputsExist(s); !ok {
+        log.Printf("Running %s again: no output matches %s", s.Name, missing)
+        return "", false
+    }
+    return fmt.Sprintf("inputs unchanged since %s", last.Succeeded.Local().Format("2006-01-02 15:04")), true
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// stepState is what the state file keeps about a step's last success.
+type stepState struct {
+    Fingerprint string    `json:"fingerprint"`
+    Succeeded   time.Time `json:"succeeded"`
+}
+
+// state is the state file, saved after every step that succeeds so an
+// interrupted run doesn't redo them.
+type state struct {
+    path  string
+    mu    sync.Mutex
+    steps map[string]stepState
+}
+
+func loadState(path string) (*state, error) {
+    s := &state{path: path, steps: make(map[string]stepState)}
+    if path == "" {
+        return s, nil
+    }
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read pipeline state: %w", err)
+    }
+    if err := json.Unmarshal(data, &s.steps); err != nil {
+        return nil, fmt.Errorf("failed to parse pipeline state %s: %w", path, err)
+    }
+    return s, nil
+}
+
+func (s *state) get(name string) (stepState, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    st, ok := s.steps[name]
+    return st, ok
+}
+
+// record notes that step name succeeded with fingerprint and saves the
+// state file.
+func (s *state) record(name, fingerprint string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.steps[name] = stepState{Fingerprint: fingerprint, Succeeded: time.Now().UTC()}
+    if s.path == "" {
+        return nil
+    }
+
+    data, err := json.MarshalIndent(s.steps, "", "  ")
+    if err != nil {
+        return err
+    }
+    // Written aside and renamed so a crash never leaves half a file
+    tmp := s.path + ".tmp"
+    if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, s.path)
+}
+
+// fingerprint hashes a step's command and the names and contents of the
+// files its inputs match.
+func fingerprint(step Step) (string, error) {
+    h := sha256.New()
+    for _, arg := range step.Command {
+        fmt.Fprintf(h, "%s\x00", arg)
+    }
+    for _, pattern := range step.Inputs {
+        matches, err := filepath.Glob(pattern)
+        if err != nil {
+            return "", fmt.Errorf("bad input pattern %q: %w", pattern, err)
+        }
+        fmt.Fprintf(h, "\x01%s\x00%d\x00", pattern, len(matches))
+        sort.Strings(matches)
+        for _, match := range matches {
+            if err := hashFile(h, match); err != nil {
+                return "", fmt.Errorf("failed to read input %s: %w", match, err)
+            }
+        }
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(w, "%s\x00", path)
+    if info.IsDir() {
+        return nil
+    }
+    fmt.Fprintf(w, "%d\x00", info.Size())
+    _, err = io.Copy(w, f)
+    return err
+}
+
+// outputsExist reports whether every output pattern of step matches a
+// file, and the first that doesn't.
+func outputsExist(step Step) (bool, string) {
+    for _, pattern := range step.Outputs {
+        matches, err := filepath.Glob(pattern)
+        if err != nil || len(matches) == 0 {
+            return false, pattern
+        }
+    }
+    return true, ""
+}
@@ -0,0 +1,182 @@
+package region
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+
+    h3 "github.com/uber/h3-go/v3"
+)
+
+const defaultRegionsFile = "regions.json"
+
+// Region is a named area made of one or more polygons. Each polygon is a
+// list of rings: the outer boundary first, then any holes.
+type Region struct {
+    Name     string
+    polygons [][][]h3.GeoCoord
+}
+
+// Regions are the regions in a file, by name.
+type Regions map[string]*Region
+
+// geoJSON is the part of a GeoJSON FeatureCollection Load reads.
+type geoJSON struct {
+    Features []struct {
+        Properties struct {
+            Name string `json:"name"`
+        } `json:"properties"`
+        Geometry struct {
+            Type        string          `json:"type"`
+            Coordinates json.RawMessage `json:"coordinates"`
+        } `json:"geometry"`
+    } `json:"features"`
+}
+
+// Load reads a GeoJSON FeatureCollection of Polygon and MultiPolygon
+// features, named by their "name" property.
+func Load(path string) (Regions, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read regions: %w", err)
+    }
+    var collection geoJSON
+    if err := json.Unmarshal(data, &collection); err != nil {
+        return nil, fmt.Errorf("failed to parse regions %s: %w", path, err)
+    }
+
+    regions := make(Regions)
+    for _, f := range collection.Features {
+        name := f.Properties.Name
+        if name == "" {
+            return nil, fmt.Errorf("region without a name in %s", path)
+        }
+        if _, ok := regions[name]; ok {
+            return nil, fmt.Errorf("region %q defined twice in %s", name, path)
+        }
+
+        // Both geometries as a MultiPolygon: polygon, ring, [lon, lat]
+        var coordinates [][][][]float64
+        switch f.Geometry.Type {
+        case "Polygon":
+            var polygon [][][]float64
+            err = json.Unmarshal(f.Geometry.Coordinates, &polygon)
+            coordinates = [][][][]float64{polygon}
+        case "MultiPolygon":
+            err = json.Unmarshal(f.Geometry.Coordinates, &coordinates)
+        default:
+            return nil, fmt.Errorf("region %q: unsupported geometry %q", name, f.Geometry.Type)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("region %q: %w", name, err)
+        }
+
+        r := &Region{Name: name}
+        for _, polygon := range coordinates {
+            var rings [][]h3.GeoCoord
+            for _, ring := range polygon {
+                var coords []h3.GeoCoord
+                for _, position := range ring {
+                    if len(position) < 2 {
+                        return nil, fmt.Errorf("region %q: position needs longitude and latitude", name)
+                    }
+                    coords = append(coords, h3.GeoCoord{Latitude: position[1], Longitude: position[0]})
+                }
+                // GeoJSON rings repeat the first position at the end
+                if n := len(coords); n > 1 && coords[0] == coords[n-1] {
+                    coords = coords[:n-1]
+                }
+                if len(coords) < 3 {
+                    return nil, fmt.Errorf("region %q: ring with fewer than 3 positions", name)
+                }
+                rings = append(rings, coords)
+            }
+            if len(rings) > 0 {
+                r.polygons = append(r.polygons, rings)
+            }
+        }
+        regions[name] = r
+    }
+    return regions, nil
+}
+
+// FromEnv loads the regions in REGIONS_FILE, by default regions.json.
+func FromEnv() (Regions, error) {
+    path := os.Getenv("REGIONS_FILE")
+    if path == "" {
+        path = defaultRegionsFile
+    }
+    return Load(path)
+}
+
+// Get returns the named region. An empty name is the whole world, which
+// is a nil *Region.
+func (rs Regions) Get(name string) (*Region, error) {
+    if name == "" {
+        return nil, nil
+    }
+    r, ok := rs[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown region %q", name)
+    }
+    return r, nil
+}
+
+// Contains reports whether a point is inside the region. A nil Region
+// contains everything.
+func (r *Region) Contains(lat, lon float64) bool {
+    if r == nil {
+        return true
+    }
+    for _, rings := range r.polygons {
+        if !inRing(rings[0], lat, lon) {
+            continue
+        }
+        inHole := false
+        for _, hole := range rings[1:] {
+            if inRing(hole, lat, lon) {
+                inHole = true
+                break
+            }
+        }
+        if !inHole {
+            return true
+        }
+    }
+    return false
+}
+
+// ContainsCell reports whether a cell's center is inside the region, the
+// same test Polyfill uses to pick cells.
+func (r *Region) ContainsCell(h3Index string) bool {
+    center := h3.ToGeo(h3.FromString(h3Index))
+    return r.Contains(center.Latitude, center.Longitude)
+}
+
+// Cells polyfills the region at a resolution. Keep it for small regions:
+// a continent at resolution 7 is millions of cells, where ContainsCell on
+// the cells at hand is far cheaper.
+func (r *Region) Cells(res int) map[string]bool {
+    cells := make(map[string]bool)
+    for _, rings := range r.polygons {
+        polygon := h3.GeoPolygon{Geofence: rings[0], Holes: rings[1:]}
+        for _, index := range h3.Polyfill(polygon, res) {
+            cells[h3.ToString(index)] = true
+        }
+    }
+    return cells
+}
+
+// inRing is an even-odd ray cast from the point towards the east.
+func inRing(ring []h3.GeoCoord, lat, lon float64) bool {
+    inside := false
+    for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+        a, b := ring[i], ring[j]
+        if (a.Latitude > lat) != (b.Latitude > lat) &&
+            lon < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+            inside = !inside
+        }
+    }
+    return inside
+}
@@ -0,0 +1,77 @@
+package rollup
+
+import (
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// DefaultHalfLife is how long a visit takes to count half as much towards
+// a cell's activity.
+const DefaultHalfLife = 7 * 24 * time.Hour
+
+// Score is a Decayed value and the time it was computed at.
+type Score struct {
+    Value float64
+    At    time.Time
+}
+
+// DecayTo returns the score as it stands at t. Every visit in it decays at the
+// same rate, so a stored score only needs aging, not recomputing.
+func (s Score) DecayTo(t time.Time, halfLife time.Duration) float64 {
+    return s.Value * decay(t.Sub(s.At), halfLife)
+}
+
+// decay is the weight left after age: 1 now, ½ after one half-life.
+func decay(age, halfLife time.Duration) float64 {
+    if age < 0 {
+        age = 0
+    }
+    return math.Exp2(-age.Hours() / halfLife.Hours())
+}
+
+// HalfLifeFromEnv reads ACTIVITY_HALF_LIFE as days ("7d") or a Go
+// duration ("36h"), defaulting to DefaultHalfLife.
+func HalfLifeFromEnv() (time.Duration, error) {
+    v := strings.TrimSpace(os.Getenv("ACTIVITY_HALF_LIFE"))
+    if v == "" {
+        return DefaultHalfLife, nil
+    }
+    var halfLife time.Duration
+    if strings.HasSuffix(v, "d") {
+        days, err := strconv.ParseFloat(strings.TrimSuffix(v, "d"), 64)
+        if err != nil {
+            return 0, fmt.Errorf("invalid ACTIVITY_HALF_LIFE %q: %w", v, err)
+        }
+        halfLife = time.Duration(days * float64(24*time.Hour))
+    } else {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return 0, fmt.Errorf("invalid ACTIVITY_HALF_LIFE %q: %w", v, err)
+        }
+        halfLife = d
+    }
+    if halfLife <= 0 {
+        return 0, fmt.Errorf("ACTIVITY_HALF_LIFE %q must be positive", v)
+    }
+    return halfLife, nil
+}
+
+// ColumnsFromEnv returns DefaultColumns with the activity half-life from
+// ACTIVITY_HALF_LIFE.
+func ColumnsFromEnv() ([]Column, error) {
+    halfLife, err := HalfLifeFromEnv()
+    if err != nil {
+        return nil, err
+    }
+    columns := append([]Column{}, DefaultColumns...)
+    for i := range columns {
+        if columns[i].Agg == Decayed {
+            columns[i].HalfLife = halfLife
+        }
+    }
+    return columns, nil
+}
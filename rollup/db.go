@@ -0,0 +1,373 @@
+package rollup
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "math"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/audit"
+)
+
+// fetchQuery selects the located places with at least $1 visits.
+const fetchQuery = `
+        SELECT city, latitude, longitude, visits, last_visit FROM cities_with_users
+        WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND COALESCE(visits, 0) >= $1`
+
+// Fetch reads every located place from cities_with_users with at least
+// minVisits visits, or with since set only those visited after it.
+func Fetch(db *sql.DB, minVisits int, since *time.Time) ([]Row, error) {
+    query := fetchQuery
+    args := []interface{}{minVisits}
+    if since != nil {
+        query += " AND last_visit > $2"
+        args = append(args, *since)
+    }
+    return fetchRows(db, query, args...)
+}
+
+// FetchNear reads the places Fetch would that lie near any of cells,
+// which must share a resolution: those inside a box around each cell,
+// padded by the box's own size on every side since H3 files some places
+// under a parent from just past its edge. It returns every place in the
+// cells and their descendants, and some around them.
+func FetchNear(db *sql.DB, minVisits int, cells []h3.H3Index) ([]Row, error) {
+    var minLat, maxLat, minLon, maxLon []float64
+    for _, cell := range cells {
+        b := nearBox(cell)
+        minLat = append(minLat, b[0])
+        maxLat = append(maxLat, b[1])
+        minLon = append(minLon, b[2])
+        maxLon = append(maxLon, b[3])
+    }
+    query := fetchQuery + `
+        AND EXISTS (
+            SELECT 1 FROM unnest($2::float8[], $3::float8[], $4::float8[], $5::float8[]) AS b(min_lat, max_lat, min_lon, max_lon)
+            WHERE latitude BETWEEN b.min_lat AND b.max_lat AND longitude BETWEEN b.min_lon AND b.max_lon)`
+    return fetchRows(db, query, minVisits, pq.Array(minLat), pq.Array(maxLat), pq.Array(minLon), pq.Array(maxLon))
+}
+
+// nearBox is the padded box FetchNear reads around cell, as min and max
+// latitude then min and max longitude.
+func nearBox(cell h3.H3Index) [4]float64 {
+    minLat, maxLat, minLon, maxLon := 90.0, -90.0, 180.0, -180.0
+    for _, v := range h3.ToGeoBoundary(cell) {
+        minLat = math.Min(minLat, v.Latitude)
+        maxLat = math.Max(maxLat, v.Latitude)
+        minLon = math.Min(minLon, v.Longitude)
+        maxLon = math.Max(maxLon, v.Longitude)
+    }
+    dLat, dLon := maxLat-minLat, maxLon-minLon
+    if dLon > 180 {
+        // Across the antimeridian or around a pole: take every longitude,
+        // and the pole on its side in case the cell holds it
+        if minLat > 0 {
+            maxLat = 90
+        } else if maxLat < 0 {
+            minLat = -90
+        }
+        return [4]float64{math.Max(minLat-dLat, -90), math.Min(maxLat+dLat, 90), -180, 180}
+    }
+    minLon, maxLon = minLon-dLon, maxLon+dLon
+    if minLon < -180 || maxLon > 180 {
+        minLon, maxLon = -180, 180
+    }
+    return [4]float64{math.Max(minLat-dLat, -90), math.Min(maxLat+dLat, 90), minLon, maxLon}
+}
+
+func fetchRows(db *sql.DB, query string, args ...interface{}) ([]Row, error) {
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query cities_with_users: %w", err)
+    }
+    defer rows.Close()
+
+    var data []Row
+    for rows.Next() {
+        var row Row
+        var city sql.NullString
+        var visits sql.NullInt64
+        var lastVisit sql.NullTime
+        if err := rows.Scan(&city, &row.Lat, &row.Lon, &visits, &lastVisit); err != nil {
+            return nil, fmt.Errorf("failed to scan row: %w", err)
+        }
+        row.City = city.String
+        row.Visits = visits.Int64
+        if lastVisit.Valid {
+            t := lastVisit.Time
+            row.LastVisit = &t
+        }
+        data = append(data, row)
+    }
+    return data, rows.Err()
+}
+
+// CheckTable returns an error if table lacks any of the columns. Tables
+// and columns come from the schema migrations, not from the jobs.
+func CheckTable(db *sql.DB, table string, columns []Column) error {
+    rows, err := db.Query("SELECT column_name FROM information_schema.columns WHERE table_name = $1", table)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    have := make(map[string]bool)
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            return err
+        }
+        have[name] = true
+    }
+    if err := rows.Err(); err != nil {
+        return err
+    }
+    if len(have) == 0 {
+        return fmt.Errorf("table %s doesn't exist, add a migration for it", table)
+    }
+
+    var missing []string
+    for _, c := range columns {
+        for _, col := range c.sqlColumns() {
+            if !have[col[0]] {
+                missing = append(missing, col[0])
+            }
+        }
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("table %s lacks columns %s, add a migration for them", table, strings.Join(missing, ", "))
+    }
+    return nil
+}
+
+// Upsert writes cells into table. Max and Latest columns keep the stored
+// value if it is larger, so cells built from just the places visited
+// since the last run merge into what is there; Sum, Count and Distinct
+// are always built from every place in the cell, so they replace it.
+func Upsert(db *sql.DB, table string, columns []Column, cells []Cell) error {
+    return write(db, Level{Table: table}, columns, cells, nil, Options{})
+}
+
+// Replace empties table and writes cells into it.
+func Replace(db *sql.DB, table string, columns []Column, cells []Cell) error {
+    return write(db, Level{Table: table}, columns, cells, nil, Options{Full: true})
+}
+
+// write stores cells in the level's table, marks the layers generated
+// from it dirty and, if watermark is set, moves the level's watermark to
+// it in the same transaction so the three never disagree. Each cell is
+// logged to opts.Audit in that transaction too, as inserted or updated
+// from what it was, and opts.Full empties the table first, logging the
+// cells it drops as deleted. Cells are
+// COPYed into a temporary staging table and merged with a single
+// statement, so table is only locked for the merge rather than a round
+// trip per cell. On a dry run all of it is rolled back.
+func write(db *sql.DB, level Level, columns []Column, cells []Cell, watermark *time.Time, opts Options) error {
+    table := level.TableName()
+    run := opts.Audit
+    names := []string{"h3_index"}
+    var updates []string
+    for _, c := range columns {
+        for _, col := range c.sqlColumns() {
+            names = append(names, col[0])
+            switch c.Agg {
+            case Max, Latest:
+                // GREATEST skips NULLs, so a cell with no last_visit keeps the old one
+                updates = append(updates, fmt.Sprintf("%[1]s = GREATEST(%[2]s.%[1]s, EXCLUDED.%[1]s)", col[0], table))
+            default:
+                updates = append(updates, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", col[0]))
+            }
+        }
+    }
+    list := strings.Join(names, ", ")
+    staging := table + "_staging"
+
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    start := time.Now()
+    _, err = tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA", staging, list, table))
+    if err != nil {
+        return fmt.Errorf("failed to create %s: %w", staging, err)
+    }
+    stmt, err := tx.Prepare(pq.CopyIn(staging, names...))
+    if err != nil {
+        return err
+    }
+    for _, cell := range cells {
+        args := []interface{}{cell.H3Index}
+        for _, v := range cell.Values {
+            if score, ok := v.(Score); ok {
+                args = append(args, score.Value, score.At)
+            } else {
+                args = append(args, v)
+            }
+        }
+        if _, err := stmt.Exec(args...); err != nil {
+            stmt.Close()
+            return fmt.Errorf("failed to copy %s for %s: %w", cell.H3Index, table, err)
+        }
+    }
+    if _, err := stmt.Exec(); err != nil {
+        stmt.Close()
+        return fmt.Errorf("failed to copy cells for %s: %w", table, err)
+    }
+    if err := stmt.Close(); err != nil {
+        return err
+    }
+    copied := time.Since(start)
+
+    start = time.Now()
+    // What the merge replaces, so the audit log can tell inserted cells
+    // from updated ones; a full rebuild replaces the whole table
+    replaced := fmt.Sprintf("SELECT %s FROM %s WHERE h3_index IN (SELECT h3_index FROM %s)", list, table, staging)
+    if opts.Full {
+        replaced = fmt.Sprintf("SELECT %s FROM %s", list, table)
+    }
+    var before map[string]map[string]interface{}
+    var existing int64
+    if run.Rows() {
+        if before, err = readCells(tx, names, replaced); err != nil {
+            return fmt.Errorf("failed to read %s: %w", table, err)
+        }
+    } else {
+        err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE h3_index IN (SELECT h3_index FROM %s)", table, staging)).Scan(&existing)
+        if err != nil {
+            return fmt.Errorf("failed to count cells in %s: %w", table, err)
+        }
+    }
+    var deleted int64
+    if opts.Full {
+        res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+        if err != nil {
+            return err
+        }
+        if deleted, err = res.RowsAffected(); err != nil {
+            return err
+        }
+    }
+    merge := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, list, list, staging)
+    if len(updates) > 0 {
+        merge += " ON CONFLICT (h3_index) DO UPDATE SET " + strings.Join(updates, ", ")
+    } else {
+        merge += " ON CONFLICT (h3_index) DO NOTHING"
+    }
+    if run.Rows() {
+        after, err := readCells(tx, names, merge+" RETURNING "+list)
+        if err != nil {
+            return fmt.Errorf("failed to merge cells into %s: %w", table, err)
+        }
+        recordMerge(run, table, cells, before, after, opts.Full)
+    } else {
+        if _, err := tx.Exec(merge); err != nil {
+            return fmt.Errorf("failed to merge cells into %s: %w", table, err)
+        }
+        run.Add(table+" inserted", int64(len(cells))-existing)
+        run.Add(table+" updated", existing)
+        if opts.Full {
+            run.Add(table+" deleted", deleted-existing)
+        }
+    }
+
+    if watermark != nil {
+        if err := setWatermark(tx, table, level.filter(opts.MinVisits), *watermark); err != nil {
+            return fmt.Errorf("failed to update watermark for %s: %w", table, err)
+        }
+    }
+    if len(cells) > 0 || opts.Full {
+        if err := markDirty(tx, table); err != nil {
+            return fmt.Errorf("failed to mark layers of %s dirty: %w", table, err)
+        }
+    }
+    if opts.DryRun {
+        log.Printf("%s: dry run, rolled back %d cells", table, len(cells))
+        return nil
+    }
+    if err := run.Flush(tx); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    merged := time.Since(start)
+
+    log.Printf("%s: copied %d cells in %s and merged them in %s (%.0f cells/s)",
+        table, len(cells), copied.Round(time.Millisecond), merged.Round(time.Millisecond), float64(len(cells))/(copied+merged).Seconds())
+    return nil
+}
+
+// readCells runs query, which returns the columns names starting with
+// h3_index, and returns its rows by cell.
+func readCells(tx *sql.Tx, names []string, query string) (map[string]map[string]interface{}, error) {
+    rows, err := tx.Query(query)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    cells := make(map[string]map[string]interface{})
+    values := make([]interface{}, len(names))
+    dest := make([]interface{}, len(names))
+    for i := range values {
+        dest[i] = &values[i]
+    }
+    for rows.Next() {
+        var h3Index string
+        dest[0] = &h3Index
+        if err := rows.Scan(dest...); err != nil {
+            return nil, err
+        }
+        row := make(map[string]interface{}, len(names)-1)
+        for i, name := range names[1:] {
+            row[name] = values[i+1]
+        }
+        cells[h3Index] = row
+    }
+    return cells, rows.Err()
+}
+
+// recordMerge records each merged cell in run as inserted or updated, and
+// on a full rebuild each cell that was left out as deleted.
+func recordMerge(run *audit.Run, table string, cells []Cell, before, after map[string]map[string]interface{}, full bool) {
+    for _, cell := range cells {
+        row, ok := after[cell.H3Index]
+        if !ok {
+            // ON CONFLICT DO NOTHING left it as it was
+            continue
+        }
+        if old, ok := before[cell.H3Index]; ok {
+            run.Record(audit.Change{Table: table, Op: "updated", Key: cell.H3Index, Before: old, After: row})
+        } else {
+            run.Record(audit.Change{Table: table, Op: "inserted", Key: cell.H3Index, After: row})
+        }
+    }
+    if !full {
+        return
+    }
+    var gone []string
+    for h3Index := range before {
+        if _, ok := after[h3Index]; !ok {
+            gone = append(gone, h3Index)
+        }
+    }
+    sort.Strings(gone)
+    for _, h3Index := range gone {
+        run.Record(audit.Change{Table: table, Op: "deleted", Key: h3Index, Before: before[h3Index]})
+    }
+}
+
+// CountRows returns the number of rows in table.
+func CountRows(db *sql.DB, table string) (int, error) {
+    var count int
+    err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+    return count, err
+}
@@ -0,0 +1,138 @@
+package rollup
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "time"
+
+    "github.com/lib/pq"
+
+    "main/audit"
+)
+
+// ChangesChannel is what the cities_with_users trigger notifies on.
+const ChangesChannel = "cities_with_users_changed"
+
+// FeedOptions control Follow.
+type FeedOptions struct {
+    Options
+    // Debounce waits this long after a notification before rolling up,
+    // so a burst of visits is rolled up once.
+    Debounce time.Duration
+    // Poll rolls up this often even without notifications, in case some
+    // were lost. 0 never polls.
+    Poll time.Duration
+    // Report, if set, is where each rollup writes its JSON diff,
+    // replacing the one before.
+    Report string
+    // DBEnv names the environment variable holding dbURL, for the audit
+    // log.
+    DBEnv string
+}
+
+// FeedOptionsFromEnv reads FEED_DEBOUNCE (default 5s) and FEED_POLL
+// (default 15m, 0 to turn it off) as Go durations.
+func FeedOptionsFromEnv() (FeedOptions, error) {
+    opts := FeedOptions{Debounce: 5 * time.Second, Poll: 15 * time.Minute}
+    for name, d := range map[string]*time.Duration{"FEED_DEBOUNCE": &opts.Debounce, "FEED_POLL": &opts.Poll} {
+        v := os.Getenv(name)
+        if v == "" {
+            continue
+        }
+        parsed, err := time.ParseDuration(v)
+        if err != nil || parsed < 0 {
+            return opts, fmt.Errorf("%s %q should be a duration like 30s", name, v)
+        }
+        *d = parsed
+    }
+    return opts, nil
+}
+
+// Follow keeps the level tables up to date until ctx is done. It rolls up
+// once to catch up, then again whenever cities_with_users notifies of a
+// change, on its own connection to dbURL. Each Run only touches the cells
+// of the places visited since the watermarks and marks the layers
+// generated from the tables it writes dirty, so a notification that is
+// lost, or arrives while a run is going, is covered by the next run.
+func Follow(ctx context.Context, dbURL string, db *sql.DB, columns []Column, levels []Level, opts FeedOptions) error {
+    listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+        if err != nil {
+            log.Printf("Change feed listener: %v", err)
+        }
+    })
+    defer listener.Close()
+    if err := listener.Listen(ChangesChannel); err != nil {
+        return fmt.Errorf("failed to listen on %s: %w", ChangesChannel, err)
+    }
+    log.Printf("Listening for changes on %s", ChangesChannel)
+
+    // Each rollup is its own audited run of the feed
+    report, dbEnv := opts.Report, opts.DBEnv
+    run := func(opts Options, reason string) {
+        start := time.Now()
+        var err error
+        opts.Audit, err = audit.Start(db, audit.Job{Name: "feed", Source: dbEnv, Dest: dbEnv, Params: map[string]interface{}{"reason": reason, "full": opts.Full}, DryRun: opts.DryRun})
+        if err != nil {
+            log.Printf("Failed to start audit log: %v", err)
+        }
+        opts.Audit.ReportTo(report)
+        err = Run(db, columns, levels, opts)
+        if finishErr := opts.Audit.Finish(err); finishErr != nil {
+            log.Printf("Failed to finish audit log: %v", finishErr)
+        }
+        if err != nil {
+            log.Printf("Rollup failed: %v", err)
+            return
+        }
+        dirty, err := DirtyLayers(db)
+        if err != nil {
+            log.Printf("Failed to read dirty layers: %v", err)
+            return
+        }
+        for _, l := range dirty {
+            log.Printf("Layer %s of %s needs regenerating, dirty since %s", l.Layer, l.Table, l.DirtySince.Format("2006-01-02 15:04"))
+        }
+        log.Printf("Rolled up in %s", time.Since(start).Round(time.Millisecond))
+    }
+
+    // Catch up on whatever changed while nothing was listening; only this
+    // run honours Full
+    run(opts.Options, "startup")
+    opts.Full = false
+
+    var poll <-chan time.Time
+    if opts.Poll > 0 {
+        ticker := time.NewTicker(opts.Poll)
+        defer ticker.Stop()
+        poll = ticker.C
+    }
+
+    var debounce <-chan time.Time
+    pending := 0
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case n := <-listener.Notify:
+            // nil after the listener reconnects, when some may be lost
+            if n == nil {
+                log.Println("Change feed reconnected")
+            }
+            pending++
+            if debounce == nil {
+                debounce = time.After(opts.Debounce)
+            }
+        case <-debounce:
+            log.Printf("Rolling up after %d notifications", pending)
+            debounce, pending = nil, 0
+            run(opts.Options, "notified")
+        case <-poll:
+            if debounce == nil {
+                run(opts.Options, "poll")
+            }
+        }
+    }
+}
@@ -0,0 +1,135 @@
+package rollup
+
+import (
+    "context"
+    "database/sql"
+    "os"
+    "testing"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/schema"
+)
+
+// TestFollow runs the change feed against TEST_POSTGRES_URL, which must be
+// a throwaway database: it empties cities_with_users and the tables the
+// rollup writes. Other packages' tests migrate it too, so run them with
+// go test -p 1.
+func TestFollow(t *testing.T) {
+    dbURL := os.Getenv("TEST_POSTGRES_URL")
+    if dbURL == "" {
+        t.Skip("TEST_POSTGRES_URL not set")
+    }
+    db, err := sql.Open("postgres", dbURL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer db.Close()
+
+    // Owned by the app that records visits, so no migration creates it
+    _, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS cities_with_users (
+            id SERIAL PRIMARY KEY,
+            city TEXT,
+            latitude DOUBLE PRECISION,
+            longitude DOUBLE PRECISION,
+            visits INT,
+            last_visit TIMESTAMP
+        )`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := schema.Up(db); err != nil {
+        t.Fatal(err)
+    }
+    for _, table := range []string{"cities_with_users", "h3_level_3", "h3_level_4", watermarkTable, dirtyTable, snapshotTable, historyTable} {
+        if _, err := db.Exec("DELETE FROM " + table); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    levels := []Level{{Resolution: 3}, {Resolution: 4}}
+    opts := FeedOptions{Debounce: 50 * time.Millisecond, DBEnv: "TEST_POSTGRES_URL"}
+    followed := make(chan error, 1)
+    go func() {
+        followed <- Follow(ctx, dbURL, db, DefaultColumns, levels, opts)
+    }()
+
+    // waitFor polls until every level table has the place's cell with
+    // visits and every layer of the tables is dirty
+    porto := h3.GeoCoord{Latitude: 41.1496, Longitude: -8.6110}
+    waitFor := func(visits int64) {
+        t.Helper()
+        deadline := time.Now().Add(10 * time.Second)
+        for {
+            missing := ""
+            for _, l := range levels {
+                var got int64
+                cell := h3.ToString(h3.FromGeo(porto, l.Resolution))
+                err := db.QueryRow("SELECT visits FROM "+l.TableName()+" WHERE h3_index = $1", cell).Scan(&got)
+                if err != nil && err != sql.ErrNoRows {
+                    t.Fatal(err)
+                }
+                if got != visits {
+                    missing = l.TableName() + " " + cell
+                }
+            }
+            dirty, err := DirtyLayers(db)
+            if err != nil {
+                t.Fatal(err)
+            }
+            marked := make(map[DirtyLayer]bool)
+            for _, d := range dirty {
+                marked[DirtyLayer{Layer: d.Layer, Table: d.Table}] = true
+            }
+            for _, l := range levels {
+                for _, layer := range Layers[l.TableName()] {
+                    if !marked[DirtyLayer{Layer: layer, Table: l.TableName()}] {
+                        missing = "dirty layer " + layer + " of " + l.TableName()
+                    }
+                }
+            }
+            if missing == "" {
+                return
+            }
+            if time.Now().After(deadline) {
+                t.Fatalf("still missing %s with %d visits", missing, visits)
+            }
+            time.Sleep(50 * time.Millisecond)
+        }
+    }
+
+    visited := time.Now().UTC().Truncate(time.Second)
+    _, err = db.Exec("INSERT INTO cities_with_users (city, latitude, longitude, visits, last_visit) VALUES ('Porto', $1, $2, 5, $3)", porto.Latitude, porto.Longitude, visited)
+    if err != nil {
+        t.Fatal(err)
+    }
+    waitFor(5)
+
+    // Once the layers are regenerated, the next visit dirties them again
+    for _, l := range levels {
+        for _, layer := range Layers[l.TableName()] {
+            if err := ClearDirty(db, layer, l.TableName()); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    _, err = db.Exec("UPDATE cities_with_users SET visits = 9, last_visit = $1 WHERE city = 'Porto'", visited.Add(time.Minute))
+    if err != nil {
+        t.Fatal(err)
+    }
+    waitFor(9)
+
+    cancel()
+    select {
+    case err := <-followed:
+        if err != nil {
+            t.Fatal(err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Follow didn't return after its context was cancelled")
+    }
+}
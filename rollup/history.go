@@ -0,0 +1,279 @@
+package rollup
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+    h3 "github.com/uber/h3-go/v3"
+)
+
+const (
+    // historyTable holds visits per cell, resolution and day or week
+    historyTable = "h3_visit_history"
+    // snapshotTable holds each place's visits as of the last run, to
+    // tell how many are new
+    snapshotTable = "rollup_place_visits"
+    // maxHistoryResolution is the finest resolution history is kept at;
+    // every coarser one down to 0 is kept too
+    maxHistoryResolution = 9
+)
+
+// Periods history is bucketed by. Weeks start on Monday.
+const (
+    Day  = "day"
+    Week = "week"
+)
+
+// Bucket returns the day or week t falls in, as midnight UTC.
+func Bucket(t time.Time, period string) time.Time {
+    day := time.Date(t.UTC().Year(), t.UTC().Month(), t.UTC().Day(), 0, 0, 0, 0, time.UTC)
+    if period == Week {
+        return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+    }
+    return day
+}
+
+type place struct {
+    lat, lon float64
+}
+
+type historyKey struct {
+    h3Index    string
+    resolution int
+    period     string
+    bucket     time.Time
+}
+
+// recordHistory adds the visits the places at each row's coordinate gained
+// since the last run to their last_visit's day and week, in every
+// resolution from 0 to 9. The first run only takes the snapshot, so
+// history starts then rather than lumping every visit ever into the day
+// of the latest one. It snapshots every place, whatever the caller's
+// filter, as jobs with different minimum visits share the snapshot: a
+// place missing from it later is new, and all its visits are. A dry run
+// rolls it back.
+func recordHistory(db *sql.DB, rows []Row, dryRun bool) error {
+    if len(rows) == 0 {
+        return nil
+    }
+
+    var seeded bool
+    if err := db.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", snapshotTable)).Scan(&seeded); err != nil {
+        return err
+    }
+
+    // Places sharing a coordinate count as one, so every place at a
+    // changed coordinate is read again: rows holds only those changed
+    // since the caller's watermark, with at least its minimum visits
+    coords := make(map[place]bool)
+    var lats, lons []float64
+    for _, row := range rows {
+        p := place{row.Lat, row.Lon}
+        if !coords[p] {
+            coords[p] = true
+            lats = append(lats, p.lat)
+            lons = append(lons, p.lon)
+        }
+    }
+    if !seeded {
+        lats, lons = nil, nil
+    }
+    current, lastVisits, err := placesAt(db, lats, lons)
+    if err != nil {
+        return err
+    }
+
+    previous := make(map[place]int64)
+    if seeded {
+        prev, err := db.Query(fmt.Sprintf(`
+            SELECT latitude, longitude, visits FROM %s
+            WHERE (latitude, longitude) IN (SELECT * FROM unnest($1::float8[], $2::float8[]))`, snapshotTable),
+            pq.Array(lats), pq.Array(lons))
+        if err != nil {
+            return err
+        }
+        defer prev.Close()
+        for prev.Next() {
+            var p place
+            var visits int64
+            if err := prev.Scan(&p.lat, &p.lon, &visits); err != nil {
+                return err
+            }
+            previous[p] = visits
+        }
+        if err := prev.Err(); err != nil {
+            return err
+        }
+    }
+
+    history := make(map[historyKey]int64)
+    if seeded {
+        for p, visits := range current {
+            delta := visits - previous[p]
+            last := lastVisits[p]
+            if delta <= 0 || last == nil {
+                continue
+            }
+            index := h3.FromGeo(h3.GeoCoord{Latitude: p.lat, Longitude: p.lon}, maxHistoryResolution)
+            for res := maxHistoryResolution; res >= 0; res-- {
+                index = h3.ToParent(index, res)
+                for _, period := range []string{Day, Week} {
+                    history[historyKey{h3.ToString(index), res, period, Bucket(*last, period)}] += delta
+                }
+            }
+        }
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    addHistory, err := tx.Prepare(fmt.Sprintf(`
+        INSERT INTO %[1]s (h3_index, resolution, period, bucket, visits) VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (h3_index, period, bucket) DO UPDATE SET visits = %[1]s.visits + EXCLUDED.visits`, historyTable))
+    if err != nil {
+        return err
+    }
+    defer addHistory.Close()
+    for key, visits := range history {
+        if _, err := addHistory.Exec(key.h3Index, key.resolution, key.period, key.bucket, visits); err != nil {
+            return fmt.Errorf("failed to add history for %s: %w", key.h3Index, err)
+        }
+    }
+
+    setSnapshot, err := tx.Prepare(fmt.Sprintf(`
+        INSERT INTO %s (latitude, longitude, visits) VALUES ($1, $2, $3)
+        ON CONFLICT (latitude, longitude) DO UPDATE SET visits = EXCLUDED.visits`, snapshotTable))
+    if err != nil {
+        return err
+    }
+    defer setSnapshot.Close()
+    for p, visits := range current {
+        if _, err := setSnapshot.Exec(p.lat, p.lon, visits); err != nil {
+            return fmt.Errorf("failed to update visit snapshot: %w", err)
+        }
+    }
+
+    if dryRun {
+        return nil
+    }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    if seeded {
+        log.Printf("Recorded %d visit history buckets", len(history))
+    } else {
+        log.Printf("Took a visit snapshot of %d places, history starts with the next run", len(current))
+    }
+    return nil
+}
+
+// placesAt sums the visits of the places at each coordinate, or at every
+// coordinate if lats is nil, and keeps the latest of their last visits.
+func placesAt(db *sql.DB, lats, lons []float64) (map[place]int64, map[place]*time.Time, error) {
+    query := `
+        SELECT latitude, longitude, visits, last_visit FROM cities_with_users
+        WHERE latitude IS NOT NULL AND longitude IS NOT NULL`
+    var args []interface{}
+    if lats != nil {
+        query += ` AND (latitude, longitude) IN (SELECT * FROM unnest($1::float8[], $2::float8[]))`
+        args = append(args, pq.Array(lats), pq.Array(lons))
+    }
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to query cities_with_users: %w", err)
+    }
+    defer rows.Close()
+
+    current := make(map[place]int64)
+    lastVisits := make(map[place]*time.Time)
+    for rows.Next() {
+        var p place
+        var visits sql.NullInt64
+        var lastVisit sql.NullTime
+        if err := rows.Scan(&p.lat, &p.lon, &visits, &lastVisit); err != nil {
+            return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+        }
+        current[p] += visits.Int64
+        if last := lastVisits[p]; lastVisit.Valid && (last == nil || lastVisit.Time.After(*last)) {
+            t := lastVisit.Time
+            lastVisits[p] = &t
+        }
+    }
+    return current, lastVisits, rows.Err()
+}
+
+// Window is a span of recent history: the last N days or weeks.
+type Window struct {
+    N      int
+    Period string
+}
+
+// String gives the window as it's written in VISITS_WINDOW, e.g. "7d".
+func (w Window) String() string {
+    return fmt.Sprintf("%d%s", w.N, w.Period[:1])
+}
+
+// Start returns the first bucket in the window ending at now.
+func (w Window) Start(now time.Time) time.Time {
+    if w.Period == Week {
+        return Bucket(now, Week).AddDate(0, 0, -7*(w.N-1))
+    }
+    return Bucket(now, Day).AddDate(0, 0, -(w.N - 1))
+}
+
+// WindowFromEnv reads VISITS_WINDOW, a number of days or weeks such as
+// "7d" or "4w". Unset means all-time counts, a nil *Window.
+func WindowFromEnv() (*Window, error) {
+    v := strings.ToLower(strings.TrimSpace(os.Getenv("VISITS_WINDOW")))
+    if v == "" {
+        return nil, nil
+    }
+    var w Window
+    switch {
+    case strings.HasSuffix(v, "d"):
+        w.Period = Day
+    case strings.HasSuffix(v, "w"):
+        w.Period = Week
+    default:
+        return nil, fmt.Errorf("VISITS_WINDOW %q should end in d or w", v)
+    }
+    n, err := strconv.Atoi(v[:len(v)-1])
+    if err != nil || n < 1 {
+        return nil, fmt.Errorf("VISITS_WINDOW %q needs a positive number of days or weeks", v)
+    }
+    w.N = n
+    return &w, nil
+}
+
+// Activity returns the visits per cell at a resolution within the window
+// ending now. Cells with no visits in it are left out.
+func Activity(db *sql.DB, resolution int, w Window) (map[string]int64, error) {
+    rows, err := db.Query(fmt.Sprintf(`
+        SELECT h3_index, SUM(visits) FROM %s
+        WHERE resolution = $1 AND period = $2 AND bucket >= $3
+        GROUP BY h3_index`, historyTable), resolution, w.Period, w.Start(time.Now()))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    activity := make(map[string]int64)
+    for rows.Next() {
+        var h3Index string
+        var visits int64
+        if err := rows.Scan(&h3Index, &visits); err != nil {
+            return nil, err
+        }
+        activity[h3Index] = visits
+    }
+    return activity, rows.Err()
+}
@@ -0,0 +1,217 @@
+package rollup
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/audit"
+)
+
+// watermarkTable holds, per level table and filter, the latest last_visit
+// already rolled into it.
+const watermarkTable = "rollup_watermarks"
+
+// Options control a Run.
+type Options struct {
+    // MinVisits skips places with fewer visits.
+    MinVisits int
+    // Full ignores the watermarks and rebuilds every table from scratch,
+    // to repair a table or pick up places that were changed without a
+    // newer last_visit.
+    Full bool
+    // DryRun rolls every table's changes back, with the history and
+    // watermarks, so only the audit log and its report show them.
+    DryRun bool
+    // Audit, if set, is told how many cells were written to each table
+    // and, with AUDIT_ROWS or a report, what they were.
+    Audit *audit.Run
+}
+
+// FullFromEnv reports whether ROLLUP_FULL asks for a full rebuild.
+func FullFromEnv() bool {
+    full, _ := strconv.ParseBool(os.Getenv("ROLLUP_FULL"))
+    return full
+}
+
+// filter names the places a level is built from, so jobs rolling up
+// different regions or minimum visits into the same table each keep
+// their own watermark, while jobs building the same thing share one.
+func (l Level) filter(minVisits int) string {
+    name := "world"
+    if l.Region != nil {
+        name = l.Region.Name
+    }
+    return fmt.Sprintf("region=%s min_visits=%d", name, minVisits)
+}
+
+func watermark(db *sql.DB, table, filter string) (*time.Time, error) {
+    var t time.Time
+    err := db.QueryRow(fmt.Sprintf("SELECT last_visit FROM %s WHERE table_name = $1 AND filter = $2", watermarkTable), table, filter).Scan(&t)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
+
+func setWatermark(tx *sql.Tx, table, filter string, t time.Time) error {
+    _, err := tx.Exec(fmt.Sprintf(`
+        INSERT INTO %s (table_name, filter, last_visit) VALUES ($1, $2, $3)
+        ON CONFLICT (table_name, filter) DO UPDATE SET last_visit = EXCLUDED.last_visit`, watermarkTable), table, filter, t)
+    return err
+}
+
+// mergeable reports whether cells built from only the changed places can
+// be merged into the stored ones, which holds when every column is a Max
+// or Latest.
+func mergeable(columns []Column) bool {
+    for _, c := range columns {
+        if c.Agg != Max && c.Agg != Latest {
+            return false
+        }
+    }
+    return true
+}
+
+// Run brings every level's table up to date with cities_with_users. Each
+// table remembers the latest last_visit rolled into it under the level's
+// region and opts.MinVisits; only places visited since are read, and the
+// cells they fall in are rewritten at every level up the hierarchy, with
+// the places around those cells read too when a column isn't a Max or
+// Latest. A level without a watermark yet is built from every place, and
+// opts.Full rebuilds all of them. The visits the places gained are added
+// to h3_visit_history on the way.
+func Run(db *sql.DB, columns []Column, levels []Level, opts Options) error {
+    // Read from the oldest watermark, or everything if any level lacks one
+    levels = append([]Level{}, levels...)
+    var since *time.Time
+    incremental := !opts.Full
+    for i, l := range levels {
+        table := l.TableName()
+        if err := CheckTable(db, table, columns); err != nil {
+            return err
+        }
+        if opts.Full {
+            continue
+        }
+        mark, err := watermark(db, table, l.filter(opts.MinVisits))
+        if err != nil {
+            return fmt.Errorf("failed to read watermark for %s: %w", table, err)
+        }
+        levels[i].Since = mark
+        if mark == nil {
+            incremental = false
+        } else if since == nil || mark.Before(*since) {
+            since = mark
+        }
+    }
+    if !incremental {
+        since = nil
+    }
+
+    changed, err := Fetch(db, opts.MinVisits, since)
+    if err != nil {
+        return err
+    }
+    var latest *time.Time
+    for _, row := range changed {
+        if row.LastVisit != nil && (latest == nil || row.LastVisit.After(*latest)) {
+            latest = row.LastVisit
+        }
+    }
+    if since != nil {
+        log.Printf("Rolling up %d places visited after %s", len(changed), since.Format("2006-01-02 15:04"))
+    } else {
+        log.Printf("Rolling up all %d places", len(changed))
+    }
+
+    if err := recordHistory(db, changed, opts.DryRun); err != nil {
+        return fmt.Errorf("failed to record visit history: %w", err)
+    }
+
+    built, err := Build(changed, columns, levels)
+    if err != nil {
+        return err
+    }
+
+    // Sums, counts and activity can't be patched with a delta, since the
+    // changed places' old share of them isn't known, so the touched cells
+    // are rebuilt from every place in them. Those are read by the touched
+    // cells' ancestors at the coarsest level, which cover the rest.
+    if since != nil && !mergeable(columns) && len(changed) > 0 {
+        coarsest := levels[0].Resolution
+        for _, l := range levels {
+            if l.Resolution < coarsest {
+                coarsest = l.Resolution
+            }
+        }
+        seen := make(map[h3.H3Index]bool)
+        var near []h3.H3Index
+        for _, cells := range built {
+            for _, cell := range cells {
+                parent := h3.ToParent(h3.FromString(cell.H3Index), coarsest)
+                if !seen[parent] {
+                    seen[parent] = true
+                    near = append(near, parent)
+                }
+            }
+        }
+        all, err := FetchNear(db, opts.MinVisits, near)
+        if err != nil {
+            return err
+        }
+        log.Printf("Rebuilding the touched cells from %d places around them", len(all))
+        full := make([]Level, len(levels))
+        for i, l := range levels {
+            full[i] = l
+            full[i].Since = nil
+        }
+        rebuilt, err := Build(all, columns, full)
+        if err != nil {
+            return err
+        }
+        for res, cells := range built {
+            touched := make(map[string]bool, len(cells))
+            for _, cell := range cells {
+                touched[cell.H3Index] = true
+            }
+            var keep []Cell
+            for _, cell := range rebuilt[res] {
+                if touched[cell.H3Index] {
+                    keep = append(keep, cell)
+                }
+            }
+            built[res] = keep
+        }
+    }
+
+    for _, l := range levels {
+        table := l.TableName()
+        cells := built[l.Resolution]
+        if l.Since != nil && (latest == nil || !latest.After(*l.Since)) {
+            log.Printf("%s: up to date", table)
+            continue
+        }
+        mark := latest
+        if mark == nil {
+            mark = l.Since
+        }
+        if err := write(db, l, columns, cells, mark, opts); err != nil {
+            return err
+        }
+        count, err := CountRows(db, table)
+        if err != nil {
+            return err
+        }
+        log.Printf("%s: %d rows in table", table, count)
+    }
+    return nil
+}
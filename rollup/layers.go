@@ -0,0 +1,66 @@
+package rollup
+
+import (
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// dirtyTable holds the GeoJSON layers due for regeneration.
+const dirtyTable = "dirty_layers"
+
+// Layers names, per level table, the jobs that generate a GeoJSON layer
+// from it. Writing to a table marks each of its layers dirty until the
+// job calls ClearDirty.
+var Layers = map[string][]string{
+    "h3_level_3": {"data2json", "pull_hexes"},
+    "h3_level_4": {"data2json", "europe"},
+    "h3_level_5": {"data2json"},
+    "h3_level_6": {"data2json"},
+    "h3_level_7": {"data2json"},
+}
+
+// DirtyLayer is a layer whose table changed since it was generated.
+type DirtyLayer struct {
+    Layer      string
+    Table      string
+    DirtySince time.Time
+}
+
+// markDirty marks table's layers dirty, keeping the time they first were.
+func markDirty(tx *sql.Tx, table string) error {
+    for _, layer := range Layers[table] {
+        _, err := tx.Exec(fmt.Sprintf(`
+            INSERT INTO %s (layer, table_name) VALUES ($1, $2)
+            ON CONFLICT (layer, table_name) DO NOTHING`, dirtyTable), layer, table)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ClearDirty records that layer has been generated from table.
+func ClearDirty(db *sql.DB, layer, table string) error {
+    _, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE layer = $1 AND table_name = $2", dirtyTable), layer, table)
+    return err
+}
+
+// DirtyLayers lists the layers due for regeneration, oldest first.
+func DirtyLayers(db *sql.DB) ([]DirtyLayer, error) {
+    rows, err := db.Query(fmt.Sprintf("SELECT layer, table_name, dirty_since FROM %s ORDER BY dirty_since, layer, table_name", dirtyTable))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var layers []DirtyLayer
+    for rows.Next() {
+        var l DirtyLayer
+        if err := rows.Scan(&l.Layer, &l.Table, &l.DirtySince); err != nil {
+            return nil, err
+        }
+        layers = append(layers, l)
+    }
+    return layers, rows.Err()
+}
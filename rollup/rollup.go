@@ -0,0 +1,249 @@
+package rollup
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+
+    "main/region"
+)
+
+// Row is one place from cities_with_users.
+type Row struct {
+    City      string
+    Lat       float64
+    Lon       float64
+    Visits    int64
+    LastVisit *time.Time
+}
+
+// Agg is how a column combines the rows that fall in a cell.
+type Agg int
+
+const (
+    Sum      Agg = iota // sum of an int field
+    Max                 // largest int field
+    Count               // number of rows, Of is ignored
+    Distinct            // number of distinct cities
+    Latest              // latest time field
+    Decayed             // sum of an int field, halved every HalfLife since last_visit
+)
+
+// Fields a Column can aggregate.
+const (
+    FieldCity      = "city"
+    FieldVisits    = "visits"
+    FieldLastVisit = "last_visit"
+)
+
+// Column is one column of an h3_level_N table and how it is computed.
+// HalfLife is only used by Decayed.
+type Column struct {
+    Name     string
+    Agg      Agg
+    Of       string
+    HalfLife time.Duration
+}
+
+// DefaultColumns are the columns every h3_level_N table has: the busiest
+// place's visits, the latest visit to any place in the cell and how
+// active the cell is now. See ColumnsFromEnv for the half-life.
+var DefaultColumns = []Column{
+    {Name: "visits", Agg: Max, Of: FieldVisits},
+    {Name: "last_visit", Agg: Latest, Of: FieldLastVisit},
+    {Name: "activity", Agg: Decayed, Of: FieldVisits, HalfLife: DefaultHalfLife},
+}
+
+// DetailColumns are written to every h3_level_N table by the full rollup,
+// after the default ones the map generators read, to describe what's
+// behind them.
+var DetailColumns = []Column{
+    {Name: "total_visits", Agg: Sum, Of: FieldVisits},
+    {Name: "places", Agg: Count},
+    {Name: "cities", Agg: Distinct, Of: FieldCity},
+}
+
+func (c Column) validate() error {
+    switch c.Agg {
+    case Sum, Max, Decayed:
+        if c.Of != FieldVisits {
+            return fmt.Errorf("column %s: can only aggregate %s", c.Name, FieldVisits)
+        }
+        if c.Agg == Decayed && c.HalfLife <= 0 {
+            return fmt.Errorf("column %s: needs a half-life", c.Name)
+        }
+    case Latest:
+        if c.Of != FieldLastVisit {
+            return fmt.Errorf("column %s: latest needs %s", c.Name, FieldLastVisit)
+        }
+    case Distinct:
+        if c.Of != FieldCity {
+            return fmt.Errorf("column %s: distinct needs %s", c.Name, FieldCity)
+        }
+    case Count:
+    default:
+        return fmt.Errorf("column %s: unknown aggregation %d", c.Name, c.Agg)
+    }
+    return nil
+}
+
+// sqlColumns are the table columns a Column is stored in, with their
+// types. A Decayed score is stored with the time it was computed at.
+func (c Column) sqlColumns() [][2]string {
+    switch c.Agg {
+    case Latest:
+        return [][2]string{{c.Name, "TIMESTAMP"}}
+    case Decayed:
+        return [][2]string{{c.Name, "DOUBLE PRECISION"}, {c.Name + "_at", "TIMESTAMP"}}
+    default:
+        return [][2]string{{c.Name, "BIGINT"}}
+    }
+}
+
+// Level is one resolution to build, limited to rows inside Region (nil
+// for everywhere) and, if Since is set, visited after it. Table defaults
+// to h3_level_<Resolution>.
+type Level struct {
+    Resolution int
+    Table      string
+    Region     *region.Region
+    Since      *time.Time
+}
+
+// TableName returns the table the level is written to.
+func (l Level) TableName() string {
+    if l.Table != "" {
+        return l.Table
+    }
+    return fmt.Sprintf("h3_level_%d", l.Resolution)
+}
+
+// Cell is one aggregated row, with a value per column in column order.
+// Latest values are *time.Time (nil if no row had a time), Decayed ones
+// Score and the rest int64.
+type Cell struct {
+    H3Index string
+    Values  []interface{}
+}
+
+// accumulator holds the running value of one column in one cell.
+type accumulator struct {
+    n      int64
+    f      float64
+    t      *time.Time
+    values map[string]struct{}
+}
+
+// Build aggregates rows into every level in a single pass: each row is
+// indexed once at the finest resolution asked for and walked up with
+// ToParent to the coarser ones. Cells come back per resolution, sorted by
+// index.
+func Build(rows []Row, columns []Column, levels []Level) (map[int][]Cell, error) {
+    for _, c := range columns {
+        if err := c.validate(); err != nil {
+            return nil, err
+        }
+    }
+    if len(levels) == 0 {
+        return map[int][]Cell{}, nil
+    }
+
+    // Finest first, so each parent is one ToParent away from the previous
+    levels = append([]Level{}, levels...)
+    sort.Slice(levels, func(i, j int) bool {
+        return levels[i].Resolution > levels[j].Resolution
+    })
+
+    cells := make(map[int]map[h3.H3Index][]accumulator, len(levels))
+    for _, l := range levels {
+        if l.Resolution < 0 || l.Resolution > 15 {
+            return nil, fmt.Errorf("invalid H3 resolution %d", l.Resolution)
+        }
+        if _, ok := cells[l.Resolution]; ok {
+            return nil, fmt.Errorf("resolution %d given twice", l.Resolution)
+        }
+        cells[l.Resolution] = make(map[h3.H3Index][]accumulator)
+    }
+
+    // Decayed scores are all as of now
+    at := time.Now().UTC()
+
+    for _, row := range rows {
+        index := h3.FromGeo(h3.GeoCoord{Latitude: row.Lat, Longitude: row.Lon}, levels[0].Resolution)
+        for _, l := range levels {
+            index = h3.ToParent(index, l.Resolution)
+            if !l.Region.Contains(row.Lat, row.Lon) {
+                continue
+            }
+            if l.Since != nil && (row.LastVisit == nil || !row.LastVisit.After(*l.Since)) {
+                continue
+            }
+            accs, ok := cells[l.Resolution][index]
+            if !ok {
+                accs = make([]accumulator, len(columns))
+                cells[l.Resolution][index] = accs
+            }
+            for i, c := range columns {
+                accs[i].add(c, row, at)
+            }
+        }
+    }
+
+    built := make(map[int][]Cell, len(levels))
+    for res, byIndex := range cells {
+        list := make([]Cell, 0, len(byIndex))
+        for index, accs := range byIndex {
+            values := make([]interface{}, len(columns))
+            for i, c := range columns {
+                values[i] = accs[i].value(c, at)
+            }
+            list = append(list, Cell{H3Index: h3.ToString(index), Values: values})
+        }
+        sort.Slice(list, func(i, j int) bool {
+            return list[i].H3Index < list[j].H3Index
+        })
+        built[res] = list
+    }
+    return built, nil
+}
+
+func (a *accumulator) add(c Column, row Row, at time.Time) {
+    switch c.Agg {
+    case Sum:
+        a.n += row.Visits
+    case Max:
+        if row.Visits > a.n {
+            a.n = row.Visits
+        }
+    case Count:
+        a.n++
+    case Distinct:
+        if a.values == nil {
+            a.values = make(map[string]struct{})
+        }
+        a.values[row.City] = struct{}{}
+    case Latest:
+        if row.LastVisit != nil && (a.t == nil || row.LastVisit.After(*a.t)) {
+            a.t = row.LastVisit
+        }
+    case Decayed:
+        if row.LastVisit != nil {
+            a.f += float64(row.Visits) * decay(at.Sub(*row.LastVisit), c.HalfLife)
+        }
+    }
+}
+
+func (a *accumulator) value(c Column, at time.Time) interface{} {
+    switch c.Agg {
+    case Latest:
+        return a.t
+    case Decayed:
+        return Score{Value: a.f, At: at}
+    case Distinct:
+        return int64(len(a.values))
+    default:
+        return a.n
+    }
+}
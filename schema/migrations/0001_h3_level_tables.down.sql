@@ -0,0 +1,7 @@
+-- The level tables predate migrations and the up file only adopts them,
+-- so they aren't dropped: reverting this would lose the production data.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0001 adopted existing tables and cannot be reverted';
+END
+$$;
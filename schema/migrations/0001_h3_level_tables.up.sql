@@ -0,0 +1,62 @@
+-- One table per H3 resolution, written by the rollup jobs and read by the
+-- map generators. IF NOT EXISTS adopts databases made before migrations.
+
+CREATE TABLE IF NOT EXISTS h3_level_0 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_1 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_2 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_3 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_4 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_5 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_6 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_7 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_8 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS h3_level_9 (
+    h3_index TEXT PRIMARY KEY,
+    visits INT,
+    last_visit TIMESTAMP
+);
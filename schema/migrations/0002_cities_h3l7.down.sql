@@ -0,0 +1,7 @@
+-- h3l7 predates migrations on the production cities_with_users, which the
+-- up file only adopts, so it isn't dropped: reverting this would lose it.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0002 adopted an existing column and cannot be reverted';
+END
+$$;
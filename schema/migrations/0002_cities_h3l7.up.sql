@@ -0,0 +1,3 @@
+-- cities_with_users is owned by the app that records visits; the jobs only
+-- add the level 7 cell of each place to it.
+ALTER TABLE cities_with_users ADD COLUMN IF NOT EXISTS h3l7 VARCHAR(15);
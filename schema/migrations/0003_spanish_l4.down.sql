@@ -0,0 +1,7 @@
+-- spanish_l4 predates migrations and the up file only adopts it, so it
+-- isn't dropped: reverting this would lose the production data.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0003 adopted an existing table and cannot be reverted';
+END
+$$;
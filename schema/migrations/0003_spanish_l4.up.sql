@@ -0,0 +1,5 @@
+-- Devices set to Spanish per level 4 cell
+CREATE TABLE IF NOT EXISTS spanish_l4 (
+    h3_index TEXT PRIMARY KEY,
+    devices INT
+);
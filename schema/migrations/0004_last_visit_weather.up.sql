@@ -0,0 +1,22 @@
+-- Weather at each cell's last visit, backfilled by visit_weather.
+-- last_visit_weather_at is the last_visit the weather belongs to.
+
+ALTER TABLE h3_level_3
+    ADD COLUMN IF NOT EXISTS last_visit_weather JSONB,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_at TIMESTAMP;
+
+ALTER TABLE h3_level_4
+    ADD COLUMN IF NOT EXISTS last_visit_weather JSONB,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_at TIMESTAMP;
+
+ALTER TABLE h3_level_5
+    ADD COLUMN IF NOT EXISTS last_visit_weather JSONB,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_at TIMESTAMP;
+
+ALTER TABLE h3_level_6
+    ADD COLUMN IF NOT EXISTS last_visit_weather JSONB,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_at TIMESTAMP;
+
+ALTER TABLE h3_level_7
+    ADD COLUMN IF NOT EXISTS last_visit_weather JSONB,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_at TIMESTAMP;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rollup_watermarks;
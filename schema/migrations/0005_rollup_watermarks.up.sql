@@ -0,0 +1,5 @@
+-- The latest last_visit already rolled into each level table
+CREATE TABLE IF NOT EXISTS rollup_watermarks (
+    table_name TEXT PRIMARY KEY,
+    last_visit TIMESTAMP NOT NULL
+);
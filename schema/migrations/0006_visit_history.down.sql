@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rollup_place_visits;
+DROP TABLE IF EXISTS h3_visit_history;
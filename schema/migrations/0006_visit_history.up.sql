@@ -0,0 +1,19 @@
+-- Visits gained per cell, resolution and day or week
+CREATE TABLE IF NOT EXISTS h3_visit_history (
+    h3_index TEXT NOT NULL,
+    resolution INT NOT NULL,
+    period TEXT NOT NULL,
+    bucket DATE NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (h3_index, period, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS h3_visit_history_window ON h3_visit_history (resolution, period, bucket);
+
+-- Each place's visits as of the last rollup, to tell how many are new
+CREATE TABLE IF NOT EXISTS rollup_place_visits (
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (latitude, longitude)
+);
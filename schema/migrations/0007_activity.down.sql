@@ -0,0 +1,39 @@
+ALTER TABLE h3_level_0
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_1
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_2
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_3
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_4
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_5
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_6
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_7
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_8
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
+
+ALTER TABLE h3_level_9
+    DROP COLUMN IF EXISTS activity,
+    DROP COLUMN IF EXISTS activity_at;
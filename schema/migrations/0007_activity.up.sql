@@ -0,0 +1,41 @@
+-- Recency weighted activity, stored with the time it was computed at
+
+ALTER TABLE h3_level_0
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_1
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_2
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_3
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_4
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_5
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_6
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_7
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_8
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
+
+ALTER TABLE h3_level_9
+    ADD COLUMN IF NOT EXISTS activity DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS activity_at TIMESTAMP;
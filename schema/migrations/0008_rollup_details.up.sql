@@ -0,0 +1,51 @@
+-- What's behind each cell, written by the rollup package (rollup/db.go)
+
+ALTER TABLE h3_level_0
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_1
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_2
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_3
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_4
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_5
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_6
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_7
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_8
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
+
+ALTER TABLE h3_level_9
+    ADD COLUMN IF NOT EXISTS total_visits BIGINT,
+    ADD COLUMN IF NOT EXISTS places BIGINT,
+    ADD COLUMN IF NOT EXISTS cities BIGINT;
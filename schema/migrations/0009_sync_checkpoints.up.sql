@@ -0,0 +1,7 @@
+-- How far through the source table each sync has got, so an interrupted
+-- or capped sync resumes after the last key it wrote
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+    name TEXT PRIMARY KEY,
+    last_key TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS dirty_layers;
+DROP TRIGGER IF EXISTS cities_with_users_changed ON cities_with_users;
+DROP FUNCTION IF EXISTS notify_cities_with_users_changed();
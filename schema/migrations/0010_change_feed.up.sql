@@ -0,0 +1,24 @@
+-- Wake the change feed consumer whenever a place is added or its visits,
+-- last visit or location change. The payload is only informational; the
+-- consumer rolls up from the watermarks, so a missed notification is
+-- picked up by the next one.
+CREATE OR REPLACE FUNCTION notify_cities_with_users_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('cities_with_users_changed', COALESCE(NEW.city, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS cities_with_users_changed ON cities_with_users;
+CREATE TRIGGER cities_with_users_changed
+    AFTER INSERT OR UPDATE OF visits, last_visit, latitude, longitude ON cities_with_users
+    FOR EACH ROW EXECUTE PROCEDURE notify_cities_with_users_changed();
+
+-- GeoJSON layers whose level table changed since the job that generates
+-- them last ran
+CREATE TABLE IF NOT EXISTS dirty_layers (
+    layer TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    dirty_since TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (layer, table_name)
+);
@@ -0,0 +1,27 @@
+-- Every run of a job that writes to this database
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT now(),
+    finished_at TIMESTAMP,
+    params JSONB,
+    source TEXT,
+    dest TEXT,
+    counts JSONB,
+    status TEXT NOT NULL,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_started ON job_runs (started_at DESC);
+
+-- The rows each run changed, when it was run with AUDIT_ROWS
+CREATE TABLE IF NOT EXISTS job_changes (
+    run_id BIGINT NOT NULL REFERENCES job_runs (id) ON DELETE CASCADE,
+    seq INT NOT NULL,
+    table_name TEXT NOT NULL,
+    op TEXT NOT NULL,
+    key TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    PRIMARY KEY (run_id, seq)
+);
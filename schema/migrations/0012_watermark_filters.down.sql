@@ -0,0 +1,4 @@
+DELETE FROM rollup_watermarks;
+ALTER TABLE rollup_watermarks DROP CONSTRAINT rollup_watermarks_pkey;
+ALTER TABLE rollup_watermarks DROP COLUMN filter;
+ALTER TABLE rollup_watermarks ADD PRIMARY KEY (table_name);
@@ -0,0 +1,7 @@
+-- Jobs that roll different regions or minimum visits into the same table
+-- each keep their own watermark. The old ones can't tell whose they were,
+-- so they go and the next run of each job rebuilds its tables once
+DELETE FROM rollup_watermarks;
+ALTER TABLE rollup_watermarks ADD COLUMN filter TEXT NOT NULL;
+ALTER TABLE rollup_watermarks DROP CONSTRAINT rollup_watermarks_pkey;
+ALTER TABLE rollup_watermarks ADD PRIMARY KEY (table_name, filter);
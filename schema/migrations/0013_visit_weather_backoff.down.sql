@@ -0,0 +1,19 @@
+ALTER TABLE h3_level_3
+    DROP COLUMN IF EXISTS last_visit_weather_failures,
+    DROP COLUMN IF EXISTS last_visit_weather_retry_at;
+
+ALTER TABLE h3_level_4
+    DROP COLUMN IF EXISTS last_visit_weather_failures,
+    DROP COLUMN IF EXISTS last_visit_weather_retry_at;
+
+ALTER TABLE h3_level_5
+    DROP COLUMN IF EXISTS last_visit_weather_failures,
+    DROP COLUMN IF EXISTS last_visit_weather_retry_at;
+
+ALTER TABLE h3_level_6
+    DROP COLUMN IF EXISTS last_visit_weather_failures,
+    DROP COLUMN IF EXISTS last_visit_weather_retry_at;
+
+ALTER TABLE h3_level_7
+    DROP COLUMN IF EXISTS last_visit_weather_failures,
+    DROP COLUMN IF EXISTS last_visit_weather_retry_at;
@@ -0,0 +1,23 @@
+-- Failed weather lookups in a row per cell, and when to try it again, so
+-- cells whose weather can't be had back off instead of taking the
+-- visit_weather backfill's slots every run. Reset once weather is stored.
+
+ALTER TABLE h3_level_3
+    ADD COLUMN IF NOT EXISTS last_visit_weather_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_retry_at TIMESTAMP;
+
+ALTER TABLE h3_level_4
+    ADD COLUMN IF NOT EXISTS last_visit_weather_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_retry_at TIMESTAMP;
+
+ALTER TABLE h3_level_5
+    ADD COLUMN IF NOT EXISTS last_visit_weather_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_retry_at TIMESTAMP;
+
+ALTER TABLE h3_level_6
+    ADD COLUMN IF NOT EXISTS last_visit_weather_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_retry_at TIMESTAMP;
+
+ALTER TABLE h3_level_7
+    ADD COLUMN IF NOT EXISTS last_visit_weather_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_visit_weather_retry_at TIMESTAMP;
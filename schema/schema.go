@@ -0,0 +1,262 @@
+package schema
+
+import (
+    "database/sql"
+    "embed"
+    "fmt"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// migrationsTable records which migrations a database has had.
+const migrationsTable = "schema_migrations"
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// fileName is NNNN_name.up.sql or NNNN_name.down.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change and how to undo it.
+type Migration struct {
+    Version int
+    Name    string
+    Up      string
+    Down    string
+}
+
+// Status is a migration and when it was applied, nil if it hasn't been.
+type Status struct {
+    Migration
+    AppliedAt *time.Time
+}
+
+// Migrations returns the migrations in version order. Every version needs
+// both an up and a down file, and versions can't repeat or skip.
+func Migrations() ([]Migration, error) {
+    entries, err := files.ReadDir("migrations")
+    if err != nil {
+        return nil, err
+    }
+
+    byVersion := make(map[int]*Migration)
+    for _, entry := range entries {
+        m := fileName.FindStringSubmatch(entry.Name())
+        if m == nil {
+            return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+        }
+        version, _ := strconv.Atoi(m[1])
+        data, err := files.ReadFile(path.Join("migrations", entry.Name()))
+        if err != nil {
+            return nil, err
+        }
+
+        migration, ok := byVersion[version]
+        if !ok {
+            migration = &Migration{Version: version, Name: m[2]}
+            byVersion[version] = migration
+        } else if migration.Name != m[2] {
+            return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, m[2])
+        }
+        if m[3] == "up" {
+            migration.Up = string(data)
+        } else {
+            migration.Down = string(data)
+        }
+    }
+
+    var migrations []Migration
+    for _, m := range byVersion {
+        if m.Up == "" || m.Down == "" {
+            return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+        }
+        migrations = append(migrations, *m)
+    }
+    sort.Slice(migrations, func(i, j int) bool {
+        return migrations[i].Version < migrations[j].Version
+    })
+    for i, m := range migrations {
+        if m.Version != i+1 {
+            return nil, fmt.Errorf("migration %d_%s is out of sequence, expected version %d", m.Version, m.Name, i+1)
+        }
+    }
+    return migrations, nil
+}
+
+// Latest returns the version this build expects.
+func Latest() (int, error) {
+    migrations, err := Migrations()
+    if err != nil {
+        return 0, err
+    }
+    return len(migrations), nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+    _, err := db.Exec(fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS %s (
+            version INT PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT now()
+        )`, migrationsTable))
+    return err
+}
+
+// applied returns when each applied version was applied, and none if db
+// has never been migrated. It only reads, so Check can run against a
+// database the jobs may not change.
+func applied(db *sql.DB) (map[int]time.Time, error) {
+    var exists bool
+    if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", migrationsTable).Scan(&exists); err != nil {
+        return nil, err
+    }
+    if !exists {
+        return map[int]time.Time{}, nil
+    }
+    rows, err := db.Query(fmt.Sprintf("SELECT version, applied_at FROM %s", migrationsTable))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    versions := make(map[int]time.Time)
+    for rows.Next() {
+        var version int
+        var at time.Time
+        if err := rows.Scan(&version, &at); err != nil {
+            return nil, err
+        }
+        versions[version] = at
+    }
+    return versions, rows.Err()
+}
+
+// Current returns the highest version applied to db, 0 for none.
+func Current(db *sql.DB) (int, error) {
+    versions, err := applied(db)
+    if err != nil {
+        return 0, err
+    }
+    current := 0
+    for version := range versions {
+        if version > current {
+            current = version
+        }
+    }
+    return current, nil
+}
+
+// StatusOf lists every migration and whether db has had it.
+func StatusOf(db *sql.DB) ([]Status, error) {
+    migrations, err := Migrations()
+    if err != nil {
+        return nil, err
+    }
+    versions, err := applied(db)
+    if err != nil {
+        return nil, err
+    }
+    statuses := make([]Status, len(migrations))
+    for i, m := range migrations {
+        statuses[i] = Status{Migration: m}
+        if at, ok := versions[m.Version]; ok {
+            statuses[i].AppliedAt = &at
+        }
+    }
+    return statuses, nil
+}
+
+// Up applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied.
+func Up(db *sql.DB) ([]Migration, error) {
+    if err := ensureMigrationsTable(db); err != nil {
+        return nil, err
+    }
+    statuses, err := StatusOf(db)
+    if err != nil {
+        return nil, err
+    }
+    var done []Migration
+    for _, s := range statuses {
+        if s.AppliedAt != nil {
+            continue
+        }
+        err := inTx(db, func(tx *sql.Tx) error {
+            if _, err := tx.Exec(s.Up); err != nil {
+                return err
+            }
+            _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", migrationsTable), s.Version, s.Name)
+            return err
+        })
+        if err != nil {
+            return done, fmt.Errorf("migration %d_%s failed: %w", s.Version, s.Name, err)
+        }
+        done = append(done, s.Migration)
+    }
+    return done, nil
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns the ones it reverted. Those that adopted tables older than the
+// migrations refuse, rather than drop production data.
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+    statuses, err := StatusOf(db)
+    if err != nil {
+        return nil, err
+    }
+    var done []Migration
+    for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+        s := statuses[i]
+        if s.AppliedAt == nil {
+            continue
+        }
+        err := inTx(db, func(tx *sql.Tx) error {
+            if _, err := tx.Exec(s.Down); err != nil {
+                return err
+            }
+            _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = $1", migrationsTable), s.Version)
+            return err
+        })
+        if err != nil {
+            return done, fmt.Errorf("reverting migration %d_%s failed: %w", s.Version, s.Name, err)
+        }
+        done = append(done, s.Migration)
+    }
+    return done, nil
+}
+
+// Check returns an error unless db is at exactly the version this build
+// expects. Jobs call it on startup so they never write to a schema they
+// don't know.
+func Check(db *sql.DB) error {
+    latest, err := Latest()
+    if err != nil {
+        return err
+    }
+    current, err := Current(db)
+    if err != nil {
+        return fmt.Errorf("failed to read schema version: %w", err)
+    }
+    switch {
+    case current < latest:
+        return fmt.Errorf("database schema is at version %d, expected %d: run hexes schema up", current, latest)
+    case current > latest:
+        return fmt.Errorf("database schema is at version %d, newer than the %d this build knows", current, latest)
+    }
+    return nil
+}
+
+func inTx(db *sql.DB, f func(tx *sql.Tx) error) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if err := f(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
@@ -0,0 +1,252 @@
+package weather
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+
+    h3 "github.com/uber/h3-go/v3"
+)
+
+// AggregateMethod says how child observations combine into a parent cell.
+type AggregateMethod string
+
+const (
+    Mean         AggregateMethod = "mean"
+    WeightedMean AggregateMethod = "weighted_mean"
+    Median       AggregateMethod = "median"
+)
+
+// AggregateMethodFromEnv reads WEATHER_AGGREGATE. An empty value means
+// parents are fetched from the provider like any other cell.
+func AggregateMethodFromEnv() (AggregateMethod, error) {
+    method := AggregateMethod(os.Getenv("WEATHER_AGGREGATE"))
+    switch method {
+    case "", Mean, WeightedMean, Median:
+        return method, nil
+    default:
+        return "", fmt.Errorf("unknown WEATHER_AGGREGATE method %q", method)
+    }
+}
+
+// Sample is a child observation and its weight (usually visits) for
+// WeightedMean. Other methods ignore the weight.
+type Sample struct {
+    Observation Observation
+    Weight      float64
+}
+
+// Aggregate combines child samples into one observation. The continuous
+// fields (temperature, humidity, pressure, wind speed, ...) use the method;
+// temp_min/temp_max are the extremes over all children; the weather code is
+// the most common one (by weight for WeightedMean) and wind direction is a
+// circular mean. Sunrise and sunset are averaged and observed_at is the
+// newest child reading.
+func Aggregate(samples []Sample, method AggregateMethod) Observation {
+    if len(samples) == 0 {
+        return Observation{}
+    }
+
+    weights := make([]float64, len(samples))
+    for i, s := range samples {
+        weights[i] = 1
+        if method == WeightedMean && s.Weight > 0 {
+            weights[i] = s.Weight
+        }
+    }
+
+    combine := func(value func(Observation) float64) float64 {
+        values := make([]float64, len(samples))
+        for i, s := range samples {
+            values[i] = value(s.Observation)
+        }
+        if method == Median {
+            return median(values)
+        }
+        return weightedMean(values, weights)
+    }
+
+    obs := Observation{
+        Temp:       combine(func(o Observation) float64 { return o.Temp }),
+        FeelsLike:  combine(func(o Observation) float64 { return o.FeelsLike }),
+        Humidity:   combine(func(o Observation) float64 { return o.Humidity }),
+        Pressure:   combine(func(o Observation) float64 { return o.Pressure }),
+        WindSpeed:  combine(func(o Observation) float64 { return o.WindSpeed }),
+        Clouds:     combine(func(o Observation) float64 { return o.Clouds }),
+        Precip1h:   combine(func(o Observation) float64 { return o.Precip1h }),
+        Visibility: combine(func(o Observation) float64 { return o.Visibility }),
+        Sunrise:    int64(combine(func(o Observation) float64 { return float64(o.Sunrise) })),
+        Sunset:     int64(combine(func(o Observation) float64 { return float64(o.Sunset) })),
+        TempMin:    samples[0].Observation.TempMin,
+        TempMax:    samples[0].Observation.TempMax,
+    }
+
+    var sinSum, cosSum float64
+    codeWeight := make(map[int]float64)
+    codeIcon := make(map[int]string)
+    for i, s := range samples {
+        obs.TempMin = math.Min(obs.TempMin, s.Observation.TempMin)
+        obs.TempMax = math.Max(obs.TempMax, s.Observation.TempMax)
+        if s.Observation.ObservedAt > obs.ObservedAt {
+            obs.ObservedAt = s.Observation.ObservedAt
+        }
+
+        rad := s.Observation.WindDeg * math.Pi / 180
+        sinSum += weights[i] * math.Sin(rad)
+        cosSum += weights[i] * math.Cos(rad)
+
+        codeWeight[s.Observation.Code] += weights[i]
+        if _, ok := codeIcon[s.Observation.Code]; !ok {
+            codeIcon[s.Observation.Code] = s.Observation.Icon
+        }
+    }
+    obs.WindDeg = math.Mod(math.Atan2(sinSum, cosSum)*180/math.Pi+360, 360)
+
+    // Most common code wins; ties go to the higher (usually more severe
+    // within a group) code so the result doesn't depend on input order.
+    for code, weight := range codeWeight {
+        if weight > codeWeight[obs.Code] || (weight == codeWeight[obs.Code] && code > obs.Code) {
+            obs.Code = code
+        }
+    }
+    obs.Icon = codeIcon[obs.Code]
+
+    return obs
+}
+
+func weightedMean(values, weights []float64) float64 {
+    var sum, total float64
+    for i, v := range values {
+        sum += v * weights[i]
+        total += weights[i]
+    }
+    return sum / total
+}
+
+func median(values []float64) float64 {
+    sorted := append([]float64(nil), values...)
+    sort.Float64s(sorted)
+    mid := len(sorted) / 2
+    if len(sorted)%2 == 0 {
+        return (sorted[mid-1] + sorted[mid]) / 2
+    }
+    return sorted[mid]
+}
+
+// AggregateParents groups child cells under their parent at resolution and
+// aggregates each group. Parents come back in first-seen order together
+// with how many children each was built from.
+func AggregateParents(cells []string, samples []Sample, resolution int, method AggregateMethod) ([]string, []Observation, []int) {
+    var parents []string
+    groups := make(map[string][]Sample)
+    for i, cell := range cells {
+        index := h3.FromString(cell)
+        if h3.Resolution(index) < resolution {
+            continue
+        }
+        parent := h3.ToString(h3.ToParent(index, resolution))
+        if _, ok := groups[parent]; !ok {
+            parents = append(parents, parent)
+        }
+        groups[parent] = append(groups[parent], samples[i])
+    }
+
+    observations := make([]Observation, len(parents))
+    counts := make([]int, len(parents))
+    for i, parent := range parents {
+        observations[i] = Aggregate(groups[parent], method)
+        counts[i] = len(groups[parent])
+    }
+    return parents, observations, counts
+}
+
+// ObservationFromProperties reads an observation back out of the
+// properties of a feature we generated earlier. It needs at least a
+// numeric temperature; fields the feature doesn't carry stay zero.
+func ObservationFromProperties(props map[string]interface{}) (Observation, bool) {
+    temp, ok := props["temperature"].(float64)
+    if !ok {
+        return Observation{}, false
+    }
+    obs := Observation{Temp: temp, TempMin: temp, TempMax: temp}
+    if v, ok := props["temp_min"].(float64); ok {
+        obs.TempMin = v
+    }
+    if v, ok := props["temp_max"].(float64); ok {
+        obs.TempMax = v
+    }
+    switch v := props["weather_code"].(type) {
+    case float64:
+        obs.Code = int(v)
+    case string:
+        obs.Code, _ = strconv.Atoi(v)
+    }
+    if v, ok := props["icon"].(string); ok {
+        obs.Icon = v
+    }
+    floats := map[string]*float64{
+        "feels_like":       &obs.FeelsLike,
+        "humidity":         &obs.Humidity,
+        "pressure":         &obs.Pressure,
+        "wind_speed":       &obs.WindSpeed,
+        "wind_deg":         &obs.WindDeg,
+        "clouds":           &obs.Clouds,
+        "precipitation_1h": &obs.Precip1h,
+        "visibility":       &obs.Visibility,
+    }
+    for name, field := range floats {
+        if v, ok := props[name].(float64); ok {
+            *field = v
+        }
+    }
+    times := map[string]*int64{
+        "sunrise":     &obs.Sunrise,
+        "sunset":      &obs.Sunset,
+        "observed_at": &obs.ObservedAt,
+    }
+    for name, field := range times {
+        if v, ok := props[name].(float64); ok {
+            *field = int64(v)
+        }
+    }
+    return obs, true
+}
+
+// ParentResults returns a result for each parent cell, aggregated from the
+// child samples that fall inside it. Only parents with no children at all
+// are fetched from the provider. counts[i] is the number of children
+// behind results[i], or 0 if it was fetched.
+func ParentResults(ctx context.Context, p Provider, opts PoolOptions, parents []string, resolution int, children []string, samples []Sample, method AggregateMethod) ([]Result, []int) {
+    derivedCells, derived, derivedCounts := AggregateParents(children, samples, resolution, method)
+    byCell := make(map[string]int, len(derivedCells))
+    for i, cell := range derivedCells {
+        byCell[cell] = i
+    }
+
+    results := make([]Result, len(parents))
+    counts := make([]int, len(parents))
+    var missing []string
+    var missingIdx []int
+    for i, parent := range parents {
+        if j, ok := byCell[parent]; ok {
+            results[i] = Result{Observation: derived[j]}
+            counts[i] = derivedCounts[j]
+            continue
+        }
+        missing = append(missing, parent)
+        missingIdx = append(missingIdx, i)
+    }
+
+    log.Printf("Derived weather for %d of %d parent cells from %d children (%s)", len(parents)-len(missing), len(parents), len(children), method)
+    if len(missing) > 0 {
+        fetched := FetchAll(ctx, p, CellPoints(missing), opts)
+        for j, i := range missingIdx {
+            results[i] = fetched[j]
+        }
+    }
+    return results, counts
+}
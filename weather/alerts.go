@@ -0,0 +1,207 @@
+package weather
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+)
+
+// AlertRule flags a cell when every condition it sets holds: Field above
+// and/or below a value (in Observation units, so wind is m/s), the weather
+// code matching one of Codes ("211", or "2xx" for a whole group), and more
+// than VisitsAbove visits.
+type AlertRule struct {
+    Name        string   `json:"name"`
+    Field       string   `json:"field,omitempty"`
+    Above       *float64 `json:"above,omitempty"`
+    Below       *float64 `json:"below,omitempty"`
+    Codes       []string `json:"codes,omitempty"`
+    VisitsAbove *int     `json:"visits_above,omitempty"`
+}
+
+func float(v float64) *float64 {
+    return &v
+}
+
+func integer(v int) *int {
+    return &v
+}
+
+// DefaultAlertRules are used for WEATHER_ALERTS=on.
+var DefaultAlertRules = []AlertRule{
+    {Name: "heat", Field: "temperature", Above: float(35)},
+    {Name: "wind", Field: "wind_speed", Above: float(16.7)}, // 60 km/h
+    {Name: "thunderstorm", Codes: []string{"2xx"}, VisitsAbove: integer(10)},
+    {Name: "snow", Codes: []string{"6xx"}, VisitsAbove: integer(10)},
+}
+
+func (r AlertRule) validate() error {
+    if r.Name == "" {
+        return fmt.Errorf("alert rule without a name")
+    }
+    if r.Field == "" && len(r.Codes) == 0 && r.VisitsAbove == nil {
+        return fmt.Errorf("alert rule %q has no conditions", r.Name)
+    }
+    if r.Field != "" {
+        if _, ok := propertyValues[r.Field]; !ok {
+            return fmt.Errorf("alert rule %q: unknown field %q", r.Name, r.Field)
+        }
+        if r.Above == nil && r.Below == nil {
+            return fmt.Errorf("alert rule %q: field %q needs above or below", r.Name, r.Field)
+        }
+    }
+    return nil
+}
+
+func (r AlertRule) matches(obs Observation, visits int) bool {
+    if r.VisitsAbove != nil && visits <= *r.VisitsAbove {
+        return false
+    }
+    if r.Field != "" {
+        var value float64
+        switch v := propertyValues[r.Field](obs).(type) {
+        case float64:
+            value = v
+        case int:
+            value = float64(v)
+        case int64:
+            value = float64(v)
+        default:
+            return false
+        }
+        if r.Above != nil && value <= *r.Above {
+            return false
+        }
+        if r.Below != nil && value >= *r.Below {
+            return false
+        }
+    }
+    if len(r.Codes) == 0 {
+        return true
+    }
+    code := strconv.Itoa(obs.Code)
+    for _, pattern := range r.Codes {
+        if len(pattern) == len(code) && strings.HasPrefix(code, strings.TrimRight(pattern, "x")) {
+            return true
+        }
+    }
+    return false
+}
+
+// AlertedCell is one entry of the alerts summary.
+type AlertedCell struct {
+    H3Index      string      `json:"h3_index"`
+    Resolution   int         `json:"resolution"`
+    ForecastStep string      `json:"forecast_step,omitempty"`
+    Alerts       []string    `json:"alerts"`
+    Visits       int         `json:"visits"`
+    LastVisit    *time.Time  `json:"last_visit,omitempty"`
+    Weather      Observation `json:"weather"`
+}
+
+// Alerts checks results against rules and remembers the cells that
+// matched, for the summary written at the end of a run.
+type Alerts struct {
+    Rules []AlertRule
+
+    mu    sync.Mutex
+    cells []AlertedCell
+}
+
+// AlertsFromEnv reads WEATHER_ALERTS: "on" for DefaultAlertRules or the
+// path of a JSON file with a list of rules. Unset or "off" returns nil,
+// which writes no alerts.
+func AlertsFromEnv() (*Alerts, error) {
+    v := strings.TrimSpace(os.Getenv("WEATHER_ALERTS"))
+    switch v {
+    case "", "off":
+        return nil, nil
+    case "on":
+        return &Alerts{Rules: DefaultAlertRules}, nil
+    }
+
+    data, err := ioutil.ReadFile(v)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read alert rules: %w", err)
+    }
+    var rules []AlertRule
+    if err := json.Unmarshal(data, &rules); err != nil {
+        return nil, fmt.Errorf("failed to parse alert rules %s: %w", v, err)
+    }
+    for _, rule := range rules {
+        if err := rule.validate(); err != nil {
+            return nil, err
+        }
+    }
+    return &Alerts{Rules: rules}, nil
+}
+
+// Apply writes the names of the rules a result matches into
+// props["alerts"] (an empty list if none) and records the cell for the
+// summary if any did. The caller fills in cell's H3Index, Visits,
+// LastVisit and ForecastStep. Failed results never alert.
+func (a *Alerts) Apply(props map[string]interface{}, cell AlertedCell, r Result) {
+    if a == nil {
+        return
+    }
+    matched := []string{}
+    if r.Err == nil {
+        for _, rule := range a.Rules {
+            if rule.matches(r.Observation, cell.Visits) {
+                matched = append(matched, rule.Name)
+            }
+        }
+    }
+    props["alerts"] = matched
+    if len(matched) == 0 {
+        return
+    }
+
+    cell.Resolution = h3.Resolution(h3.FromString(cell.H3Index))
+    cell.Alerts = matched
+    cell.Weather = r.Observation
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.cells = append(a.cells, cell)
+}
+
+// WriteSummary writes every alerted cell so far to path, busiest first.
+func (a *Alerts) WriteSummary(path string) error {
+    if a == nil {
+        return nil
+    }
+    a.mu.Lock()
+    cells := append([]AlertedCell{}, a.cells...)
+    a.mu.Unlock()
+
+    sort.SliceStable(cells, func(i, j int) bool {
+        if cells[i].Visits != cells[j].Visits {
+            return cells[i].Visits > cells[j].Visits
+        }
+        return cells[i].H3Index < cells[j].H3Index
+    })
+
+    summary := map[string]interface{}{
+        "generated_at": time.Now().UTC().Format(time.RFC3339),
+        "rules":        a.Rules,
+        "cells":        cells,
+    }
+    data, err := json.MarshalIndent(summary, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        return err
+    }
+    return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,183 @@
+package weather
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+const (
+    defaultCacheFile = "cache/weather_cache.json"
+    defaultCacheTTL  = 45 * time.Minute
+)
+
+// cacheEntry is one stored observation and when it was fetched.
+type cacheEntry struct {
+    Observation Observation `json:"observation"`
+    FetchedAt   time.Time   `json:"fetched_at"`
+}
+
+// Cache is a JSON file backed store of observations keyed by provider and
+// rounded centroid. It outlives the process, so every generator and every
+// level pass of a run (and the next run of the deploy loop) can reuse
+// observations younger than the TTL.
+type Cache struct {
+    path string
+    ttl  time.Duration
+
+    mu      sync.Mutex
+    entries map[string]cacheEntry
+    hits    int
+    misses  int
+}
+
+// OpenCache loads the cache at path, starting empty if it doesn't exist.
+func OpenCache(path string, ttl time.Duration) (*Cache, error) {
+    c := &Cache{
+        path:    path,
+        ttl:     ttl,
+        entries: make(map[string]cacheEntry),
+    }
+
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return c, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read weather cache: %w", err)
+    }
+    if err := json.Unmarshal(data, &c.entries); err != nil {
+        log.Printf("Ignoring unreadable weather cache %s: %v", path, err)
+        c.entries = make(map[string]cacheEntry)
+    }
+    return c, nil
+}
+
+// CacheFromEnv opens the cache at WEATHER_CACHE_FILE with WEATHER_CACHE_TTL
+// (a Go duration). WEATHER_CACHE_FILE=off disables caching and returns nil.
+func CacheFromEnv() (*Cache, error) {
+    path := os.Getenv("WEATHER_CACHE_FILE")
+    if path == "off" {
+        return nil, nil
+    }
+    if path == "" {
+        path = defaultCacheFile
+    }
+    ttl := defaultCacheTTL
+    if v, err := time.ParseDuration(os.Getenv("WEATHER_CACHE_TTL")); err == nil && v > 0 {
+        ttl = v
+    }
+    return OpenCache(path, ttl)
+}
+
+func cacheKey(provider string, p Point) string {
+    // Four decimals is ~10m, finer than any H3 level we export, so each
+    // cell centroid gets its own key.
+    return fmt.Sprintf("%s:%.4f,%.4f", provider, p.Lat, p.Lon)
+}
+
+// Get returns a cached observation if one is younger than the TTL.
+func (c *Cache) Get(provider string, p Point) (Observation, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    entry, ok := c.entries[cacheKey(provider, p)]
+    if !ok || time.Since(entry.FetchedAt) > c.ttl {
+        c.misses++
+        return Observation{}, false
+    }
+    c.hits++
+    return entry.Observation, true
+}
+
+// Put stores an observation fetched now.
+func (c *Cache) Put(provider string, p Point, obs Observation) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.entries[cacheKey(provider, p)] = cacheEntry{Observation: obs, FetchedAt: time.Now()}
+}
+
+// Save merges the cache with what other processes saved since it was
+// opened, keeping the newer of two observations of a point, drops expired
+// entries and writes it back to disk. The file is written to a temporary
+// file beside it and renamed over it, so a killed run never leaves it half
+// written and parallel runs never write the same temporary file.
+func (c *Cache) Save() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    var saved map[string]cacheEntry
+    data, err := ioutil.ReadFile(c.path)
+    switch {
+    case os.IsNotExist(err):
+    case err != nil:
+        return fmt.Errorf("failed to read weather cache: %w", err)
+    default:
+        if err := json.Unmarshal(data, &saved); err != nil {
+            log.Printf("Replacing unreadable weather cache %s: %v", c.path, err)
+        }
+    }
+    for key, entry := range saved {
+        if mine, ok := c.entries[key]; !ok || entry.FetchedAt.After(mine.FetchedAt) {
+            c.entries[key] = entry
+        }
+    }
+    for key, entry := range c.entries {
+        if time.Since(entry.FetchedAt) > c.ttl {
+            delete(c.entries, key)
+        }
+    }
+
+    data, err = json.Marshal(c.entries)
+    if err != nil {
+        return err
+    }
+    dir := filepath.Dir(c.path)
+    if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Chmod(0644); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), c.path)
+}
+
+// Stats returns the hits and misses since the cache was opened.
+func (c *Cache) Stats() (hits, misses int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.hits, c.misses
+}
+
+// LogStats prints the hit rate for the run.
+func (c *Cache) LogStats() {
+    if c == nil {
+        return
+    }
+    hits, misses := c.Stats()
+    total := hits + misses
+    rate := 0.0
+    if total > 0 {
+        rate = 100 * float64(hits) / float64(total)
+    }
+    log.Printf("Weather cache: %d hits, %d misses (%.1f%% hit rate)", hits, misses, rate)
+}
@@ -0,0 +1,150 @@
+package weather
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/rivo/uniseg"
+)
+
+const defaultCodesFile = "weather_codes.json"
+
+// codeEntry is one condition in weather_codes.json. condition is English;
+// translations are keyed by language code (es, pt, de, fr, ...).
+type codeEntry struct {
+    Emoji        string            `json:"emoji"`
+    Phases       PhaseEmojis       `json:"phases"`
+    Condition    string            `json:"condition"`
+    Translations map[string]string `json:"translations"`
+}
+
+// Conditions names weather codes in English and each requested language,
+// and picks their emoji.
+type Conditions struct {
+    codes map[string]codeEntry
+    langs []string
+}
+
+// LoadConditions reads the catalogue at path for langs. Codes that lack a
+// translation fall back to English, and are logged here once per language
+// so gaps in the catalogue get noticed.
+func LoadConditions(path string, langs []string) (*Conditions, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read weather codes: %w", err)
+    }
+    c := &Conditions{langs: langs}
+    if err := json.Unmarshal(data, &c.codes); err != nil {
+        return nil, fmt.Errorf("failed to parse weather codes: %w", err)
+    }
+
+    for _, lang := range langs {
+        missing := c.Missing(lang)
+        switch {
+        case len(missing) == len(c.codes):
+            log.Printf("Weather codes have no %q translations, using English", lang)
+        case len(missing) > 0:
+            log.Printf("Weather codes missing %q translations, using English for: %s", lang, strings.Join(missing, ", "))
+        }
+    }
+    return c, nil
+}
+
+// ConditionsFromEnv loads the catalogue from WEATHER_CODES_FILE (default
+// weather_codes.json) for the comma separated WEATHER_LOCALES, e.g.
+// "es,pt". Without WEATHER_LOCALES it returns nil and no condition
+// properties are written.
+func ConditionsFromEnv() (*Conditions, error) {
+    v := strings.TrimSpace(os.Getenv("WEATHER_LOCALES"))
+    if v == "" {
+        return nil, nil
+    }
+    var langs []string
+    for _, lang := range strings.Split(v, ",") {
+        if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" && lang != "en" {
+            langs = append(langs, lang)
+        }
+    }
+
+    return LoadConditions(CodesFile(), langs)
+}
+
+// CodesFile is WEATHER_CODES_FILE, by default weather_codes.json.
+func CodesFile() string {
+    if path := os.Getenv("WEATHER_CODES_FILE"); path != "" {
+        return path
+    }
+    return defaultCodesFile
+}
+
+// Missing lists the codes with no translation for lang, in code order.
+func (c *Conditions) Missing(lang string) []string {
+    var missing []string
+    for code, entry := range c.codes {
+        if entry.Translations[lang] == "" {
+            missing = append(missing, code)
+        }
+    }
+    sort.Strings(missing)
+    return missing
+}
+
+// Condition names a code in lang, falling back to English. ok is false if
+// the code isn't in the catalogue at all.
+func (c *Conditions) Condition(code int, lang string) (string, bool) {
+    entry, ok := c.codes[strconv.Itoa(code)]
+    if !ok {
+        return "", false
+    }
+    if text := entry.Translations[lang]; text != "" {
+        return text, true
+    }
+    return entry.Condition, true
+}
+
+// Emoji returns a code's emoji at phase, or its first plain emoji if it
+// lists no phases. Unknown codes get none.
+func (c *Conditions) Emoji(code int, phase Phase) string {
+    if c == nil {
+        return ""
+    }
+    entry, ok := c.codes[strconv.Itoa(code)]
+    if !ok {
+        return ""
+    }
+    if emoji := entry.Phases.At(phase); emoji != "" {
+        return emoji
+    }
+    graphemes := uniseg.NewGraphemes(entry.Emoji)
+    graphemes.Next()
+    return graphemes.Str()
+}
+
+// Apply writes condition (English) and condition_<lang> for each language
+// into props. Failed results and unknown codes get null. A nil Conditions
+// writes nothing.
+func (c *Conditions) Apply(props map[string]interface{}, r Result) {
+    if c == nil {
+        return
+    }
+    langs := append([]string{"en"}, c.langs...)
+    for _, lang := range langs {
+        key := "condition_" + lang
+        if lang == "en" {
+            key = "condition"
+        }
+        props[key] = nil
+        if r.Err != nil {
+            continue
+        }
+        if text, ok := c.Condition(r.Observation.Code, lang); ok {
+            props[key] = text
+        }
+    }
+}
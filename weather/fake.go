@@ -0,0 +1,75 @@
+package weather
+
+import (
+    "context"
+    "math"
+    "time"
+)
+
+// Fake makes up plausible weather from the coordinate and time, with no
+// network and no API key. The same point and hour always give the same
+// observation, so runs against it can be compared.
+type Fake struct{}
+
+func (Fake) Name() string {
+    return "fake"
+}
+
+func (f Fake) Current(ctx context.Context, lat, lon float64) (Observation, error) {
+    return f.at(lat, lon, time.Now()), nil
+}
+
+func (f Fake) Forecast(ctx context.Context, lat, lon float64, steps []time.Duration) ([]Observation, error) {
+    now := time.Now()
+    observations := make([]Observation, len(steps))
+    for i, step := range steps {
+        observations[i] = f.at(lat, lon, now.Add(step))
+    }
+    return observations, nil
+}
+
+func (f Fake) At(ctx context.Context, lat, lon float64, t time.Time) (Observation, error) {
+    return f.at(lat, lon, t), nil
+}
+
+// at is colder towards the poles and warmest mid afternoon local time.
+func (Fake) at(lat, lon float64, t time.Time) Observation {
+    hour := t.UTC().Truncate(time.Hour)
+    solarHour := math.Mod(float64(hour.Hour())+lon/15+24, 24)
+    daily := 5 * math.Sin(2*math.Pi*(solarHour-9)/24)
+    temp := math.Round((28-0.5*math.Abs(lat)+daily)*10) / 10
+
+    codes := []int{800, 801, 802, 804, 500, 741}
+    code := codes[int(math.Abs(lat*7+lon*3)+float64(hour.Unix()/3600))%len(codes)]
+    day := solarHour >= 6 && solarHour < 18
+
+    clouds, precip := 100.0, 0.0
+    if code >= 800 {
+        clouds = math.Min(float64(code-800)*25, 100)
+    }
+    if code == 500 {
+        precip = 0.8
+    }
+
+    midnight := time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.UTC)
+    noon := midnight.Add(time.Duration((12 - lon/15) * float64(time.Hour)))
+
+    return Observation{
+        Temp:       temp,
+        TempMin:    temp - 4,
+        TempMax:    temp + 4,
+        FeelsLike:  temp - 1,
+        Code:       code,
+        Icon:       IconForCode(code, day),
+        Humidity:   60,
+        Pressure:   1013,
+        WindSpeed:  3,
+        WindDeg:    math.Mod(lon+360, 360),
+        Clouds:     clouds,
+        Precip1h:   precip,
+        Visibility: 10000,
+        Sunrise:    noon.Add(-6 * time.Hour).Unix(),
+        Sunset:     noon.Add(6 * time.Hour).Unix(),
+        ObservedAt: hour.Unix(),
+    }
+}
@@ -0,0 +1,172 @@
+package weather
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "time"
+)
+
+// ForecastProvider is implemented by providers that can predict the
+// weather at a coordinate. Observations come back one per step, where each
+// step is an offset from now.
+type ForecastProvider interface {
+    Provider
+    Forecast(ctx context.Context, lat, lon float64, steps []time.Duration) ([]Observation, error)
+}
+
+// BatchForecastProvider answers forecasts for many coordinates in one
+// round trip. forecasts[i][s] belongs to points[i] and steps[s].
+type BatchForecastProvider interface {
+    ForecastProvider
+    ForecastBatch(ctx context.Context, points []Point, steps []time.Duration) ([][]Observation, error)
+}
+
+// DefaultForecastSteps are the offsets trip planners asked for.
+var DefaultForecastSteps = []time.Duration{3 * time.Hour, 24 * time.Hour, 72 * time.Hour}
+
+// ForecastStepsFromEnv reads WEATHER_FORECAST, a comma separated list of
+// offsets such as "3h,24h,72h", or "on" for DefaultForecastSteps. An empty
+// value turns forecast layers off and returns nil.
+func ForecastStepsFromEnv() ([]time.Duration, error) {
+    v := strings.TrimSpace(os.Getenv("WEATHER_FORECAST"))
+    switch v {
+    case "", "off":
+        return nil, nil
+    case "on":
+        return DefaultForecastSteps, nil
+    }
+
+    var steps []time.Duration
+    for _, field := range strings.Split(v, ",") {
+        step, err := time.ParseDuration(strings.TrimSpace(field))
+        if err != nil || step <= 0 || step%time.Hour != 0 {
+            return nil, fmt.Errorf("invalid WEATHER_FORECAST step %q, want whole hours such as 24h", field)
+        }
+        steps = append(steps, step)
+    }
+    return steps, nil
+}
+
+// StepLabel names a step in file names and properties, e.g. "24h".
+func StepLabel(step time.Duration) string {
+    return fmt.Sprintf("%dh", int(step.Hours()))
+}
+
+// AsForecast returns p as a ForecastProvider, or an error naming the
+// provider if it can't forecast.
+func AsForecast(p Provider) (ForecastProvider, error) {
+    fp, ok := p.(ForecastProvider)
+    if !ok {
+        return nil, fmt.Errorf("weather provider %s has no forecast support", p.Name())
+    }
+    return fp, nil
+}
+
+// FetchForecast is FetchAll for forecasts: results[s][i] is the forecast
+// for points[i] at steps[s], so each step can be written out like a
+// current weather layer. Each point/step pair is cached on its own.
+func FetchForecast(ctx context.Context, p ForecastProvider, points []Point, steps []time.Duration, opts PoolOptions) [][]Result {
+    results := make([][]Result, len(steps))
+    for s := range steps {
+        results[s] = make([]Result, len(points))
+    }
+    cacheName := func(step time.Duration) string {
+        return p.Name() + "+" + StepLabel(step)
+    }
+
+    // A point needs a request unless every step is cached
+    pending := make([]int, 0, len(points))
+    for i, point := range points {
+        cached := opts.Cache != nil
+        for s, step := range steps {
+            if !cached {
+                break
+            }
+            var obs Observation
+            if obs, cached = opts.Cache.Get(cacheName(step), point); cached {
+                results[s][i] = Result{Observation: obs}
+            }
+        }
+        if !cached {
+            pending = append(pending, i)
+        }
+    }
+    if len(pending) < len(points) {
+        log.Printf("Using cached forecast data for %d of %d points", len(points)-len(pending), len(points))
+        opts.Stats.add(0, len(points)-len(pending), 0, 0)
+    }
+
+    batchSize := 1
+    bp, isBatch := p.(BatchForecastProvider)
+    if isBatch {
+        batchSize = openMeteoMaxBatch
+    }
+
+    fetch := func(reqCtx context.Context, batch []int) error {
+        if isBatch {
+            forecasts, err := bp.ForecastBatch(reqCtx, pointsAt(points, batch), steps)
+            if err != nil {
+                return err
+            }
+            for j, i := range batch {
+                for s := range steps {
+                    results[s][i] = Result{Observation: forecasts[j][s]}
+                }
+            }
+            return nil
+        }
+        i := batch[0]
+        forecast, err := p.Forecast(reqCtx, points[i].Lat, points[i].Lon, steps)
+        if err != nil {
+            return err
+        }
+        for s := range steps {
+            results[s][i] = Result{Observation: forecast[s]}
+        }
+        return nil
+    }
+
+    finish := func(batch []int, err error) {
+        for _, i := range batch {
+            for s, step := range steps {
+                if err != nil {
+                    results[s][i] = Result{Err: err}
+                } else if opts.Cache != nil {
+                    opts.Cache.Put(cacheName(step), points[i], results[s][i].Observation)
+                }
+            }
+        }
+    }
+
+    runBatches(ctx, pending, batchSize, opts, fetch, finish)
+
+    if opts.Cache != nil {
+        if err := opts.Cache.Save(); err != nil {
+            log.Printf("Failed to save weather cache: %v", err)
+        }
+    }
+
+    return results
+}
+
+// nearestTime returns the index of the time in times (Unix seconds)
+// closest to target, or -1 if times is empty.
+func nearestTime(times []int64, target int64) int {
+    best := -1
+    for i, t := range times {
+        if best < 0 || abs64(t-target) < abs64(times[best]-target) {
+            best = i
+        }
+    }
+    return best
+}
+
+func abs64(v int64) int64 {
+    if v < 0 {
+        return -v
+    }
+    return v
+}
@@ -0,0 +1,56 @@
+package weather
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+// HistoricalProvider is implemented by providers that can look up the
+// weather at a coordinate at some point in the past.
+type HistoricalProvider interface {
+    Provider
+    At(ctx context.Context, lat, lon float64, t time.Time) (Observation, error)
+}
+
+// AsHistorical returns p as a HistoricalProvider, or an error naming the
+// provider if it has no historical endpoint.
+func AsHistorical(p Provider) (HistoricalProvider, error) {
+    hp, ok := p.(HistoricalProvider)
+    if !ok {
+        return nil, fmt.Errorf("weather provider %s has no historical support", p.Name())
+    }
+    return hp, nil
+}
+
+// FetchHistory is FetchAll for past weather: results[i] is the weather at
+// points[i] at times[i]. History doesn't change, so nothing is cached; the
+// caller stores the results.
+func FetchHistory(ctx context.Context, p HistoricalProvider, points []Point, times []time.Time, opts PoolOptions) []Result {
+    results := make([]Result, len(points))
+    pending := make([]int, len(points))
+    for i := range points {
+        pending[i] = i
+    }
+
+    fetch := func(reqCtx context.Context, batch []int) error {
+        i := batch[0]
+        obs, err := p.At(reqCtx, points[i].Lat, points[i].Lon, times[i])
+        if err != nil {
+            return err
+        }
+        results[i] = Result{Observation: obs}
+        return nil
+    }
+
+    finish := func(batch []int, err error) {
+        if err != nil {
+            for _, i := range batch {
+                results[i] = Result{Err: err}
+            }
+        }
+    }
+
+    runBatches(ctx, pending, 1, opts, fetch, finish)
+    return results
+}
@@ -0,0 +1,414 @@
+package weather
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    openMeteoBaseURL    = "https://api.open-meteo.com/v1"
+    openMeteoArchiveURL = "https://archive-api.open-meteo.com/v1"
+
+    // Open-Meteo accepts comma separated coordinate lists; keep each
+    // request well under its URL length limit.
+    openMeteoMaxBatch = 100
+)
+
+// OpenMeteo talks to the Open-Meteo forecast API, and to its archive API
+// for past weather. It needs no API key and can answer many coordinates in
+// one request through CurrentBatch.
+type OpenMeteo struct {
+    BaseURL    string
+    ArchiveURL string
+    Client     *http.Client
+}
+
+// NewOpenMeteo returns a provider for the public Open-Meteo API. Point
+// BaseURL (and ArchiveURL) at a local server to replay recorded responses.
+func NewOpenMeteo(baseURL string) *OpenMeteo {
+    if baseURL == "" {
+        baseURL = openMeteoBaseURL
+    }
+    return &OpenMeteo{
+        BaseURL:    strings.TrimRight(baseURL, "/"),
+        ArchiveURL: openMeteoArchiveURL,
+        Client:     http.DefaultClient,
+    }
+}
+
+func (o *OpenMeteo) Name() string {
+    return "open-meteo"
+}
+
+// omForecast mirrors one location of the /forecast response. Times are
+// requested as Unix seconds.
+type omForecast struct {
+    Current *struct {
+        Time          int64   `json:"time"`
+        Temperature   float64 `json:"temperature_2m"`
+        FeelsLike     float64 `json:"apparent_temperature"`
+        Humidity      float64 `json:"relative_humidity_2m"`
+        Pressure      float64 `json:"pressure_msl"`
+        CloudCover    float64 `json:"cloud_cover"`
+        Precipitation float64 `json:"precipitation"`
+        Visibility    float64 `json:"visibility"`
+        WeatherCode   int     `json:"weather_code"`
+        WindSpeed     float64 `json:"wind_speed_10m"`
+        WindDirection float64 `json:"wind_direction_10m"`
+        IsDay         int     `json:"is_day"`
+    } `json:"current"`
+    Hourly struct {
+        Time          []int64   `json:"time"`
+        Temperature   []float64 `json:"temperature_2m"`
+        FeelsLike     []float64 `json:"apparent_temperature"`
+        Humidity      []float64 `json:"relative_humidity_2m"`
+        Pressure      []float64 `json:"pressure_msl"`
+        CloudCover    []float64 `json:"cloud_cover"`
+        Precipitation []float64 `json:"precipitation"`
+        Visibility    []float64 `json:"visibility"`
+        WeatherCode   []int     `json:"weather_code"`
+        WindSpeed     []float64 `json:"wind_speed_10m"`
+        WindDirection []float64 `json:"wind_direction_10m"`
+        IsDay         []int     `json:"is_day"`
+    } `json:"hourly"`
+    Daily struct {
+        Time    []int64   `json:"time"`
+        TempMax []float64 `json:"temperature_2m_max"`
+        TempMin []float64 `json:"temperature_2m_min"`
+        Sunrise []int64   `json:"sunrise"`
+        Sunset  []int64   `json:"sunset"`
+    } `json:"daily"`
+}
+
+// omVariables are requested for both current and hourly data. The archive
+// has no visibility, so it gets omArchiveVariables.
+const (
+    omVariables        = "temperature_2m,apparent_temperature,relative_humidity_2m,pressure_msl,cloud_cover,precipitation,visibility,weather_code,wind_speed_10m,wind_direction_10m,is_day"
+    omArchiveVariables = "temperature_2m,apparent_temperature,relative_humidity_2m,pressure_msl,cloud_cover,precipitation,weather_code,wind_speed_10m,wind_direction_10m,is_day"
+)
+
+func (o *OpenMeteo) Current(ctx context.Context, lat, lon float64) (Observation, error) {
+    observations, err := o.CurrentBatch(ctx, []Point{{Lat: lat, Lon: lon}})
+    if err != nil {
+        return Observation{}, err
+    }
+    return observations[0], nil
+}
+
+// CurrentBatch fetches all points, splitting them into as few requests as
+// the API allows. Results are in the same order as points.
+func (o *OpenMeteo) CurrentBatch(ctx context.Context, points []Point) ([]Observation, error) {
+    observations := make([]Observation, 0, len(points))
+    for start := 0; start < len(points); start += openMeteoMaxBatch {
+        end := start + openMeteoMaxBatch
+        if end > len(points) {
+            end = len(points)
+        }
+        chunk, err := o.fetch(ctx, points[start:end])
+        if err != nil {
+            return nil, err
+        }
+        observations = append(observations, chunk...)
+    }
+    return observations, nil
+}
+
+func (o *OpenMeteo) fetch(ctx context.Context, points []Point) ([]Observation, error) {
+    q := url.Values{}
+    q.Set("current", omVariables)
+    q.Set("forecast_days", "1")
+
+    forecasts, err := o.get(ctx, o.BaseURL+"/forecast", points, q)
+    if err != nil {
+        return nil, err
+    }
+
+    observations := make([]Observation, len(forecasts))
+    for i, f := range forecasts {
+        if f.Current == nil {
+            return nil, fmt.Errorf("invalid response format")
+        }
+        code := WMOToCode(f.Current.WeatherCode)
+        obs := Observation{
+            Temp:       f.Current.Temperature,
+            TempMin:    f.Current.Temperature,
+            TempMax:    f.Current.Temperature,
+            FeelsLike:  f.Current.FeelsLike,
+            Code:       code,
+            Icon:       IconForCode(code, f.Current.IsDay == 1),
+            Humidity:   f.Current.Humidity,
+            Pressure:   f.Current.Pressure,
+            WindSpeed:  f.Current.WindSpeed,
+            WindDeg:    f.Current.WindDirection,
+            Clouds:     f.Current.CloudCover,
+            Precip1h:   f.Current.Precipitation,
+            Visibility: f.Current.Visibility,
+            ObservedAt: f.Current.Time,
+        }
+        if len(f.Daily.TempMin) > 0 && len(f.Daily.TempMax) > 0 {
+            obs.TempMin = f.Daily.TempMin[0]
+            obs.TempMax = f.Daily.TempMax[0]
+        }
+        if len(f.Daily.Sunrise) > 0 && len(f.Daily.Sunset) > 0 {
+            obs.Sunrise = f.Daily.Sunrise[0]
+            obs.Sunset = f.Daily.Sunset[0]
+        }
+        observations[i] = obs
+    }
+    return observations, nil
+}
+
+func (o *OpenMeteo) Forecast(ctx context.Context, lat, lon float64, steps []time.Duration) ([]Observation, error) {
+    forecasts, err := o.ForecastBatch(ctx, []Point{{Lat: lat, Lon: lon}}, steps)
+    if err != nil {
+        return nil, err
+    }
+    return forecasts[0], nil
+}
+
+// ForecastBatch reads the hour closest to now+step for each step from the
+// hourly forecast, with that day's min/max and sunrise/sunset.
+func (o *OpenMeteo) ForecastBatch(ctx context.Context, points []Point, steps []time.Duration) ([][]Observation, error) {
+    var longest time.Duration
+    for _, step := range steps {
+        if step > longest {
+            longest = step
+        }
+    }
+    // Days are local, so allow one more than the longest step needs
+    days := int(longest/(24*time.Hour)) + 2
+    if days > 16 {
+        days = 16
+    }
+
+    q := url.Values{}
+    q.Set("hourly", omVariables)
+    q.Set("forecast_days", strconv.Itoa(days))
+
+    forecasts := make([][]Observation, 0, len(points))
+    for start := 0; start < len(points); start += openMeteoMaxBatch {
+        end := start + openMeteoMaxBatch
+        if end > len(points) {
+            end = len(points)
+        }
+        chunk, err := o.get(ctx, o.BaseURL+"/forecast", points[start:end], q)
+        if err != nil {
+            return nil, err
+        }
+
+        now := time.Now()
+        for _, f := range chunk {
+            observations := make([]Observation, len(steps))
+            for s, step := range steps {
+                obs, ok := f.hourlyObservation(now.Add(step))
+                if !ok {
+                    return nil, fmt.Errorf("invalid response format")
+                }
+                observations[s] = obs
+            }
+            forecasts = append(forecasts, observations)
+        }
+    }
+    return forecasts, nil
+}
+
+// At reads the hour closest to t from the archive, with that day's min/max
+// and sunrise/sunset. The archive lags a few days behind real time.
+func (o *OpenMeteo) At(ctx context.Context, lat, lon float64, t time.Time) (Observation, error) {
+    // A day either side covers the location's local day around t
+    q := url.Values{}
+    q.Set("hourly", omArchiveVariables)
+    q.Set("start_date", t.UTC().AddDate(0, 0, -1).Format("2006-01-02"))
+    q.Set("end_date", t.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+
+    forecasts, err := o.get(ctx, strings.TrimRight(o.ArchiveURL, "/")+"/archive", []Point{{Lat: lat, Lon: lon}}, q)
+    if err != nil {
+        return Observation{}, err
+    }
+    obs, ok := forecasts[0].hourlyObservation(t)
+    if !ok {
+        return Observation{}, fmt.Errorf("invalid response format")
+    }
+    return obs, nil
+}
+
+// hourlyObservation builds an observation from the hour closest to t, with
+// the min/max and sunrise/sunset of the day that hour falls in.
+func (f omForecast) hourlyObservation(t time.Time) (Observation, bool) {
+    h := f.Hourly
+    i := nearestTime(h.Time, t.Unix())
+    if i < 0 || i >= len(h.Temperature) || i >= len(h.WeatherCode) {
+        return Observation{}, false
+    }
+    code := WMOToCode(h.WeatherCode[i])
+    obs := Observation{
+        Temp:       h.Temperature[i],
+        TempMin:    h.Temperature[i],
+        TempMax:    h.Temperature[i],
+        Code:       code,
+        Icon:       IconForCode(code, at(h.IsDay, i) == 1),
+        FeelsLike:  at(h.FeelsLike, i),
+        Humidity:   at(h.Humidity, i),
+        Pressure:   at(h.Pressure, i),
+        WindSpeed:  at(h.WindSpeed, i),
+        WindDeg:    at(h.WindDirection, i),
+        Clouds:     at(h.CloudCover, i),
+        Precip1h:   at(h.Precipitation, i),
+        Visibility: at(h.Visibility, i),
+        ObservedAt: h.Time[i],
+    }
+
+    // The day the hour falls in
+    for d, day := range f.Daily.Time {
+        if day > h.Time[i] {
+            break
+        }
+        obs.TempMin = at(f.Daily.TempMin, d)
+        obs.TempMax = at(f.Daily.TempMax, d)
+        obs.Sunrise = at(f.Daily.Sunrise, d)
+        obs.Sunset = at(f.Daily.Sunset, d)
+    }
+    return obs, true
+}
+
+// get requests q from endpoint for points, adding the coordinates and the
+// settings every request shares, and returns one forecast per point.
+func (o *OpenMeteo) get(ctx context.Context, endpoint string, points []Point, q url.Values) ([]omForecast, error) {
+    lats := make([]string, len(points))
+    lons := make([]string, len(points))
+    for i, p := range points {
+        lats[i] = strconv.FormatFloat(p.Lat, 'f', 4, 64)
+        lons[i] = strconv.FormatFloat(p.Lon, 'f', 4, 64)
+    }
+
+    q.Set("latitude", strings.Join(lats, ","))
+    q.Set("longitude", strings.Join(lons, ","))
+    q.Set("daily", "temperature_2m_max,temperature_2m_min,sunrise,sunset")
+    q.Set("wind_speed_unit", "ms")
+    q.Set("timezone", "auto")
+    q.Set("timeformat", "unixtime")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := o.Client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    body, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return nil, err
+    }
+    if resp.StatusCode != http.StatusOK {
+        return nil, newStatusError(resp, body)
+    }
+
+    // A single coordinate comes back as an object, several as an array.
+    var forecasts []omForecast
+    if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+        err = json.Unmarshal(body, &forecasts)
+    } else {
+        forecasts = make([]omForecast, 1)
+        err = json.Unmarshal(body, &forecasts[0])
+    }
+    if err != nil {
+        return nil, err
+    }
+    if len(forecasts) != len(points) {
+        return nil, fmt.Errorf("expected %d locations in response, got %d", len(points), len(forecasts))
+    }
+    return forecasts, nil
+}
+
+// at returns values[i], or zero if the API left the series short.
+func at[T any](values []T, i int) T {
+    var zero T
+    if i < 0 || i >= len(values) {
+        return zero
+    }
+    return values[i]
+}
+
+// wmoCodes maps WMO weather interpretation codes onto the condition ids
+// weather_codes.json is keyed by.
+var wmoCodes = map[int]int{
+    0:  800, // Clear sky
+    1:  801, // Mainly clear
+    2:  802, // Partly cloudy
+    3:  804, // Overcast
+    45: 741, // Fog
+    48: 741, // Depositing rime fog
+    51: 300, // Light drizzle
+    53: 301, // Drizzle
+    55: 302, // Dense drizzle
+    56: 511, // Light freezing drizzle
+    57: 511, // Dense freezing drizzle
+    61: 500, // Slight rain
+    63: 501, // Moderate rain
+    65: 502, // Heavy rain
+    66: 511, // Light freezing rain
+    67: 511, // Heavy freezing rain
+    71: 600, // Slight snow
+    73: 601, // Moderate snow
+    75: 602, // Heavy snow
+    77: 600, // Snow grains
+    80: 520, // Slight rain showers
+    81: 521, // Moderate rain showers
+    82: 522, // Violent rain showers
+    85: 620, // Slight snow showers
+    86: 622, // Heavy snow showers
+    95: 211, // Thunderstorm
+    96: 201, // Thunderstorm with slight hail
+    99: 202, // Thunderstorm with heavy hail
+}
+
+// WMOToCode converts a WMO weather code to its weather_codes.json id.
+// Unknown codes map to 0.
+func WMOToCode(wmo int) int {
+    return wmoCodes[wmo]
+}
+
+// IconForCode builds an OpenWeatherMap style icon name ("10d", "01n") for
+// a condition id so providers without icons still mark day and night.
+func IconForCode(code int, day bool) string {
+    var icon string
+    switch {
+    case code >= 200 && code < 300:
+        icon = "11"
+    case code >= 300 && code < 400:
+        icon = "09"
+    case code == 511:
+        icon = "13"
+    case code >= 500 && code < 520:
+        icon = "10"
+    case code >= 520 && code < 600:
+        icon = "09"
+    case code >= 600 && code < 700:
+        icon = "13"
+    case code >= 700 && code < 800:
+        icon = "50"
+    case code == 800:
+        icon = "01"
+    case code == 801:
+        icon = "02"
+    case code == 802:
+        icon = "03"
+    default:
+        icon = "04"
+    }
+    if day {
+        return icon + "d"
+    }
+    return icon + "n"
+}
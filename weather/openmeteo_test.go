@@ -0,0 +1,230 @@
+package weather
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// serveFile answers every request with a recorded response.
+func serveFile(t *testing.T, status int, name string) *httptest.Server {
+    t.Helper()
+    body, err := ioutil.ReadFile("testdata/" + name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        w.Write(body)
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestOpenMeteoCurrentBatch(t *testing.T) {
+    srv := serveFile(t, http.StatusOK, "openmeteo_current.json")
+    om := NewOpenMeteo(srv.URL)
+
+    got, err := om.CurrentBatch(context.Background(), []Point{{Lat: 41.14, Lon: -8.62}, {Lat: 38.72, Lon: -9.14}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []Observation{
+        {
+            Temp: 17.4, TempMin: 14.1, TempMax: 19.8, FeelsLike: 16.9,
+            Code: 500, Icon: "10d",
+            Humidity: 88, Pressure: 1012.3, WindSpeed: 4.3, WindDeg: 215,
+            Clouds: 100, Precip1h: 1.2, Visibility: 8400,
+            Sunrise: 1760597254, Sunset: 1760637651, ObservedAt: 1760608800,
+        },
+        {
+            Temp: 21.2, TempMin: 15.9, TempMax: 23.5, FeelsLike: 20.6,
+            Code: 801, Icon: "02n",
+            Humidity: 64, Pressure: 1014.8, WindSpeed: 3.1, WindDeg: 320,
+            Clouds: 12, Precip1h: 0, Visibility: 24140,
+            Sunrise: 1760597170, Sunset: 1760638032, ObservedAt: 1760608800,
+        },
+    }
+    if len(got) != len(want) {
+        t.Fatalf("got %d observations, want %d", len(got), len(want))
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("observation %d:\n got %+v\nwant %+v", i, got[i], want[i])
+        }
+    }
+}
+
+func TestOpenMeteoCurrentSingle(t *testing.T) {
+    // One coordinate comes back as an object rather than an array
+    srv := serveFile(t, http.StatusOK, "openmeteo_current_single.json")
+    obs, err := NewOpenMeteo(srv.URL).Current(context.Background(), 41.14, -8.62)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if obs.Temp != 17.4 || obs.Code != 500 || obs.Icon != "10d" {
+        t.Errorf("got %+v", obs)
+    }
+}
+
+func TestOpenMeteoCurrentBatchChunks(t *testing.T) {
+    recorded, err := ioutil.ReadFile("testdata/openmeteo_current_single.json")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Answers each coordinate with the recorded location, its temperature
+    // set to the latitude asked for so the order can be checked
+    var mu sync.Mutex
+    var sizes []int
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lats := strings.Split(r.URL.Query().Get("latitude"), ",")
+        lons := strings.Split(r.URL.Query().Get("longitude"), ",")
+        if len(lats) != len(lons) {
+            http.Error(w, `{"error":true,"reason":"coordinate lists differ in length"}`, http.StatusBadRequest)
+            return
+        }
+        mu.Lock()
+        sizes = append(sizes, len(lats))
+        mu.Unlock()
+
+        var locations []map[string]interface{}
+        for _, lat := range lats {
+            var location map[string]interface{}
+            if err := json.Unmarshal(recorded, &location); err != nil {
+                t.Error(err)
+            }
+            temp, _ := strconv.ParseFloat(lat, 64)
+            location["current"].(map[string]interface{})["temperature_2m"] = temp
+            locations = append(locations, location)
+        }
+        json.NewEncoder(w).Encode(locations)
+    }))
+    defer srv.Close()
+
+    points := make([]Point, 2*openMeteoMaxBatch+50)
+    for i := range points {
+        points[i] = Point{Lat: float64(i) / 10, Lon: -8.6}
+    }
+    got, err := NewOpenMeteo(srv.URL).CurrentBatch(context.Background(), points)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    want := []int{openMeteoMaxBatch, openMeteoMaxBatch, 50}
+    if len(sizes) != len(want) {
+        t.Fatalf("got requests of %v coordinates, want %v", sizes, want)
+    }
+    for i := range want {
+        if sizes[i] != want[i] {
+            t.Fatalf("got requests of %v coordinates, want %v", sizes, want)
+        }
+    }
+    if len(got) != len(points) {
+        t.Fatalf("got %d observations for %d points", len(got), len(points))
+    }
+    for i, obs := range got {
+        if obs.Temp != points[i].Lat {
+            t.Fatalf("observation %d is for latitude %v, want %v", i, obs.Temp, points[i].Lat)
+        }
+    }
+}
+
+func TestOpenMeteoErrors(t *testing.T) {
+    ctx := context.Background()
+
+    t.Run("bad request", func(t *testing.T) {
+        srv := serveFile(t, http.StatusBadRequest, "openmeteo_error.json")
+        _, err := NewOpenMeteo(srv.URL).Current(ctx, 123, -8.62)
+        var statusErr *StatusError
+        if !errors.As(err, &statusErr) {
+            t.Fatalf("got %v, want a StatusError", err)
+        }
+        if statusErr.StatusCode != http.StatusBadRequest || !strings.Contains(statusErr.Body, "Latitude must be in range") {
+            t.Errorf("got %+v", statusErr)
+        }
+        if retryable(err) {
+            t.Error("a bad request shouldn't be retried")
+        }
+    })
+
+    t.Run("rate limited", func(t *testing.T) {
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Retry-After", "7")
+            http.Error(w, `{"error":true,"reason":"Too many requests"}`, http.StatusTooManyRequests)
+        }))
+        defer srv.Close()
+        _, err := NewOpenMeteo(srv.URL).Current(ctx, 41.14, -8.62)
+        var statusErr *StatusError
+        if !errors.As(err, &statusErr) {
+            t.Fatalf("got %v, want a StatusError", err)
+        }
+        if statusErr.RetryAfter.Seconds() != 7 {
+            t.Errorf("got Retry-After %s, want 7s", statusErr.RetryAfter)
+        }
+        if !retryable(err) {
+            t.Error("a 429 should be retried")
+        }
+    })
+
+    t.Run("missing locations", func(t *testing.T) {
+        srv := serveFile(t, http.StatusOK, "openmeteo_current.json")
+        points := []Point{{Lat: 41.14, Lon: -8.62}, {Lat: 38.72, Lon: -9.14}, {Lat: 40.2, Lon: -8.4}}
+        if _, err := NewOpenMeteo(srv.URL).CurrentBatch(ctx, points); err == nil {
+            t.Error("expected an error for 2 locations in answer to 3")
+        }
+    })
+
+    t.Run("no current data", func(t *testing.T) {
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Write([]byte(`{"latitude":41.14,"longitude":-8.62}`))
+        }))
+        defer srv.Close()
+        if _, err := NewOpenMeteo(srv.URL).Current(ctx, 41.14, -8.62); err == nil {
+            t.Error("expected an error for a response without current data")
+        }
+    })
+}
+
+func TestWMOToCode(t *testing.T) {
+    tests := []struct {
+        wmo  int
+        code int
+        day  string
+        icon string
+    }{
+        {0, 800, "01d", "01n"},
+        {2, 802, "03d", "03n"},
+        {3, 804, "04d", "04n"},
+        {45, 741, "50d", "50n"},
+        {55, 302, "09d", "09n"},
+        {57, 511, "13d", "13n"},
+        {63, 501, "10d", "10n"},
+        {81, 521, "09d", "09n"},
+        {75, 602, "13d", "13n"},
+        {95, 211, "11d", "11n"},
+        {99, 202, "11d", "11n"},
+        {42, 0, "04d", "04n"},
+    }
+    for _, tt := range tests {
+        code := WMOToCode(tt.wmo)
+        if code != tt.code {
+            t.Errorf("WMOToCode(%d) = %d, want %d", tt.wmo, code, tt.code)
+            continue
+        }
+        if icon := IconForCode(code, true); icon != tt.day {
+            t.Errorf("IconForCode(%d, day) = %s, want %s", code, icon, tt.day)
+        }
+        if icon := IconForCode(code, false); icon != tt.icon {
+            t.Errorf("IconForCode(%d, night) = %s, want %s", code, icon, tt.icon)
+        }
+    }
+}
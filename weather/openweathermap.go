@@ -0,0 +1,284 @@
+package weather
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+const (
+    openWeatherMapBaseURL    = "https://api.openweathermap.org/data/2.5"
+    openWeatherMapHistoryURL = "https://api.openweathermap.org/data/3.0"
+)
+
+// OpenWeatherMap talks to the OpenWeatherMap 2.5 current weather API, and
+// to the One Call 3.0 time machine for past weather. The time machine
+// needs a One Call subscription on the key.
+type OpenWeatherMap struct {
+    APIKey     string
+    BaseURL    string
+    HistoryURL string
+    Client     *http.Client
+}
+
+// NewOpenWeatherMap returns a provider for the public OpenWeatherMap API.
+func NewOpenWeatherMap(apiKey string) *OpenWeatherMap {
+    return &OpenWeatherMap{
+        APIKey:     apiKey,
+        BaseURL:    openWeatherMapBaseURL,
+        HistoryURL: openWeatherMapHistoryURL,
+        Client:     http.DefaultClient,
+    }
+}
+
+func (o *OpenWeatherMap) Name() string {
+    return "openweathermap"
+}
+
+// owmCurrent mirrors the parts of the /weather response we use.
+type owmCurrent struct {
+    Dt   int64 `json:"dt"`
+    Main *struct {
+        Temp      float64 `json:"temp"`
+        FeelsLike float64 `json:"feels_like"`
+        TempMin   float64 `json:"temp_min"`
+        TempMax   float64 `json:"temp_max"`
+        Pressure  float64 `json:"pressure"`
+        Humidity  float64 `json:"humidity"`
+    } `json:"main"`
+    Weather []struct {
+        ID   int    `json:"id"`
+        Icon string `json:"icon"`
+    } `json:"weather"`
+    Wind struct {
+        Speed float64 `json:"speed"`
+        Deg   float64 `json:"deg"`
+    } `json:"wind"`
+    Clouds struct {
+        All float64 `json:"all"`
+    } `json:"clouds"`
+    Rain struct {
+        OneHour    float64 `json:"1h"`
+        ThreeHours float64 `json:"3h"`
+    } `json:"rain"`
+    Snow struct {
+        OneHour    float64 `json:"1h"`
+        ThreeHours float64 `json:"3h"`
+    } `json:"snow"`
+    Visibility float64 `json:"visibility"`
+    Sys        struct {
+        Sunrise int64 `json:"sunrise"`
+        Sunset  int64 `json:"sunset"`
+    } `json:"sys"`
+}
+
+// owmForecast mirrors the /forecast response: 3 hourly steps for 5 days.
+type owmForecast struct {
+    List []owmCurrent `json:"list"`
+    City struct {
+        Sunrise int64 `json:"sunrise"`
+        Sunset  int64 `json:"sunset"`
+    } `json:"city"`
+}
+
+func (c owmCurrent) observation() Observation {
+    obs := Observation{
+        Temp:       c.Main.Temp,
+        TempMin:    c.Main.TempMin,
+        TempMax:    c.Main.TempMax,
+        FeelsLike:  c.Main.FeelsLike,
+        Humidity:   c.Main.Humidity,
+        Pressure:   c.Main.Pressure,
+        WindSpeed:  c.Wind.Speed,
+        WindDeg:    c.Wind.Deg,
+        Clouds:     c.Clouds.All,
+        Precip1h:   c.Rain.OneHour + c.Snow.OneHour,
+        Visibility: c.Visibility,
+        Sunrise:    c.Sys.Sunrise,
+        Sunset:     c.Sys.Sunset,
+        ObservedAt: c.Dt,
+    }
+    // Forecast steps only report the 3h total
+    if obs.Precip1h == 0 {
+        obs.Precip1h = (c.Rain.ThreeHours + c.Snow.ThreeHours) / 3
+    }
+    if len(c.Weather) > 0 {
+        obs.Code = c.Weather[0].ID
+        obs.Icon = c.Weather[0].Icon
+    }
+    return obs
+}
+
+func (o *OpenWeatherMap) Current(ctx context.Context, lat, lon float64) (Observation, error) {
+    q := url.Values{}
+    q.Set("lat", fmt.Sprintf("%f", lat))
+    q.Set("lon", fmt.Sprintf("%f", lon))
+    q.Set("appid", o.APIKey)
+    q.Set("units", "metric")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.BaseURL+"/weather?"+q.Encode(), nil)
+    if err != nil {
+        return Observation{}, err
+    }
+    resp, err := o.Client.Do(req)
+    if err != nil {
+        return Observation{}, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        bodyBytes, _ := ioutil.ReadAll(resp.Body)
+        return Observation{}, newStatusError(resp, bodyBytes)
+    }
+
+    var result owmCurrent
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return Observation{}, err
+    }
+    if result.Main == nil {
+        return Observation{}, fmt.Errorf("invalid response format")
+    }
+
+    return result.observation(), nil
+}
+
+// Forecast picks the 3 hourly step closest to now+step for each step.
+// Sunrise and sunset are the city's for today.
+func (o *OpenWeatherMap) Forecast(ctx context.Context, lat, lon float64, steps []time.Duration) ([]Observation, error) {
+    q := url.Values{}
+    q.Set("lat", fmt.Sprintf("%f", lat))
+    q.Set("lon", fmt.Sprintf("%f", lon))
+    q.Set("appid", o.APIKey)
+    q.Set("units", "metric")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.BaseURL+"/forecast?"+q.Encode(), nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := o.Client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        bodyBytes, _ := ioutil.ReadAll(resp.Body)
+        return nil, newStatusError(resp, bodyBytes)
+    }
+
+    var result owmForecast
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, err
+    }
+
+    times := make([]int64, len(result.List))
+    for i, item := range result.List {
+        if item.Main == nil {
+            return nil, fmt.Errorf("invalid response format")
+        }
+        times[i] = item.Dt
+    }
+
+    now := time.Now()
+    observations := make([]Observation, len(steps))
+    for s, step := range steps {
+        i := nearestTime(times, now.Add(step).Unix())
+        if i < 0 {
+            return nil, fmt.Errorf("invalid response format")
+        }
+        obs := result.List[i].observation()
+        obs.Sunrise = result.City.Sunrise
+        obs.Sunset = result.City.Sunset
+        observations[s] = obs
+    }
+    return observations, nil
+}
+
+// owmTimeMachine mirrors the parts of the /onecall/timemachine response we
+// use. It is flat, unlike /weather.
+type owmTimeMachine struct {
+    Data []struct {
+        Dt         int64   `json:"dt"`
+        Sunrise    int64   `json:"sunrise"`
+        Sunset     int64   `json:"sunset"`
+        Temp       float64 `json:"temp"`
+        FeelsLike  float64 `json:"feels_like"`
+        Pressure   float64 `json:"pressure"`
+        Humidity   float64 `json:"humidity"`
+        Clouds     float64 `json:"clouds"`
+        Visibility float64 `json:"visibility"`
+        WindSpeed  float64 `json:"wind_speed"`
+        WindDeg    float64 `json:"wind_deg"`
+        Weather    []struct {
+            ID   int    `json:"id"`
+            Icon string `json:"icon"`
+        } `json:"weather"`
+        Rain struct {
+            OneHour float64 `json:"1h"`
+        } `json:"rain"`
+        Snow struct {
+            OneHour float64 `json:"1h"`
+        } `json:"snow"`
+    } `json:"data"`
+}
+
+// At asks the time machine for the hour containing t. It only returns a
+// single reading, so temp_min and temp_max equal the temperature.
+func (o *OpenWeatherMap) At(ctx context.Context, lat, lon float64, t time.Time) (Observation, error) {
+    q := url.Values{}
+    q.Set("lat", fmt.Sprintf("%f", lat))
+    q.Set("lon", fmt.Sprintf("%f", lon))
+    q.Set("dt", fmt.Sprintf("%d", t.Unix()))
+    q.Set("appid", o.APIKey)
+    q.Set("units", "metric")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.HistoryURL+"/onecall/timemachine?"+q.Encode(), nil)
+    if err != nil {
+        return Observation{}, err
+    }
+    resp, err := o.Client.Do(req)
+    if err != nil {
+        return Observation{}, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        bodyBytes, _ := ioutil.ReadAll(resp.Body)
+        return Observation{}, newStatusError(resp, bodyBytes)
+    }
+
+    var result owmTimeMachine
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return Observation{}, err
+    }
+    if len(result.Data) == 0 {
+        return Observation{}, fmt.Errorf("invalid response format")
+    }
+
+    d := result.Data[0]
+    obs := Observation{
+        Temp:       d.Temp,
+        TempMin:    d.Temp,
+        TempMax:    d.Temp,
+        FeelsLike:  d.FeelsLike,
+        Humidity:   d.Humidity,
+        Pressure:   d.Pressure,
+        WindSpeed:  d.WindSpeed,
+        WindDeg:    d.WindDeg,
+        Clouds:     d.Clouds,
+        Precip1h:   d.Rain.OneHour + d.Snow.OneHour,
+        Visibility: d.Visibility,
+        Sunrise:    d.Sunrise,
+        Sunset:     d.Sunset,
+        ObservedAt: d.Dt,
+    }
+    if len(d.Weather) > 0 {
+        obs.Code = d.Weather[0].ID
+        obs.Icon = d.Weather[0].Icon
+    }
+    return obs, nil
+}
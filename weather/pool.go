@@ -0,0 +1,231 @@
+package weather
+
+import (
+    "context"
+    "log"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    h3 "github.com/uber/h3-go/v3"
+    "golang.org/x/time/rate"
+)
+
+// PoolOptions bounds how hard FetchAll hits a provider. Cache is optional;
+// when set, fresh cached observations are used instead of fetching. Stats,
+// when set, collects outcomes across every FetchAll of the run.
+type PoolOptions struct {
+    Workers           int
+    RequestsPerSecond float64
+    Timeout           time.Duration
+    Retry             RetryPolicy
+    Cache             *Cache
+    Stats             *RunStats
+}
+
+// DefaultPoolOptions keeps well inside the OpenWeatherMap free tier of 60
+// calls a minute per key.
+var DefaultPoolOptions = PoolOptions{
+    Workers:           4,
+    RequestsPerSecond: 1,
+    Timeout:           10 * time.Second,
+    Retry:             DefaultRetryPolicy,
+}
+
+// PoolOptionsFromEnv overrides DefaultPoolOptions with WEATHER_WORKERS,
+// WEATHER_RPS and WEATHER_TIMEOUT (a Go duration such as "15s"), and sets
+// up the retry policy and run stats from the environment.
+func PoolOptionsFromEnv() PoolOptions {
+    opts := DefaultPoolOptions
+    opts.Retry = RetryPolicyFromEnv()
+    opts.Stats = NewRunStats()
+    if v, err := strconv.Atoi(os.Getenv("WEATHER_WORKERS")); err == nil && v > 0 {
+        opts.Workers = v
+    }
+    if v, err := strconv.ParseFloat(os.Getenv("WEATHER_RPS"), 64); err == nil && v > 0 {
+        opts.RequestsPerSecond = v
+    }
+    if v, err := time.ParseDuration(os.Getenv("WEATHER_TIMEOUT")); err == nil && v > 0 {
+        opts.Timeout = v
+    }
+    return opts
+}
+
+// Result is the outcome of fetching one point.
+type Result struct {
+    Observation Observation
+    Err         error
+}
+
+// Values for the weather_status property written on every feature.
+const (
+    StatusOK     = "ok"
+    StatusFailed = "failed"
+)
+
+// Status is the weather_status to write for this result.
+func (r Result) Status() string {
+    if r.Err != nil {
+        return StatusFailed
+    }
+    return StatusOK
+}
+
+// FetchAll fetches every point with a bounded number of workers, never
+// exceeding opts.RequestsPerSecond. results[i] always belongs to points[i],
+// so callers can build output in input order. Batch providers get one
+// request per batch instead of one per point. Cancelling ctx stops new
+// requests; unfetched points come back with ctx.Err().
+func FetchAll(ctx context.Context, p Provider, points []Point, opts PoolOptions) []Result {
+    results := make([]Result, len(points))
+
+    // Only points without a fresh cached observation need a request
+    pending := make([]int, 0, len(points))
+    for i, point := range points {
+        if opts.Cache != nil {
+            if obs, ok := opts.Cache.Get(p.Name(), point); ok {
+                results[i] = Result{Observation: obs}
+                continue
+            }
+        }
+        pending = append(pending, i)
+    }
+    if len(pending) < len(points) {
+        log.Printf("Using cached weather data for %d of %d points", len(points)-len(pending), len(points))
+        opts.Stats.add(0, len(points)-len(pending), 0, 0)
+    }
+
+    batchSize := 1
+    bp, isBatch := p.(BatchProvider)
+    if isBatch {
+        batchSize = openMeteoMaxBatch
+    }
+
+    fetch := func(reqCtx context.Context, batch []int) error {
+        if isBatch {
+            observations, err := bp.CurrentBatch(reqCtx, pointsAt(points, batch))
+            if err != nil {
+                return err
+            }
+            for j, i := range batch {
+                results[i] = Result{Observation: observations[j]}
+            }
+            return nil
+        }
+        i := batch[0]
+        obs, err := p.Current(reqCtx, points[i].Lat, points[i].Lon)
+        if err != nil {
+            return err
+        }
+        results[i] = Result{Observation: obs}
+        return nil
+    }
+
+    finish := func(batch []int, err error) {
+        for _, i := range batch {
+            if err != nil {
+                results[i] = Result{Err: err}
+            } else if opts.Cache != nil {
+                opts.Cache.Put(p.Name(), points[i], results[i].Observation)
+            }
+        }
+    }
+
+    runBatches(ctx, pending, batchSize, opts, fetch, finish)
+
+    if opts.Cache != nil {
+        if err := opts.Cache.Save(); err != nil {
+            log.Printf("Failed to save weather cache: %v", err)
+        }
+    }
+
+    return results
+}
+
+// runBatches splits pending into batches and hands them to opts.Workers
+// workers, never exceeding opts.RequestsPerSecond. fetch is retried under
+// opts.Retry with a fresh timeout per attempt; finish is then called once
+// per batch with the final error.
+func runBatches(ctx context.Context, pending []int, batchSize int, opts PoolOptions, fetch func(context.Context, []int) error, finish func([]int, error)) {
+    if opts.Workers <= 0 {
+        opts.Workers = 1
+    }
+    limiter := rate.NewLimiter(rate.Inf, 1)
+    if opts.RequestsPerSecond > 0 {
+        limiter = rate.NewLimiter(rate.Limit(opts.RequestsPerSecond), 1)
+    }
+
+    jobs := make(chan []int)
+    var wg sync.WaitGroup
+    var done int
+    var mu sync.Mutex
+
+    for w := 0; w < opts.Workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for batch := range jobs {
+                if err := limiter.Wait(ctx); err != nil {
+                    finish(batch, err)
+                    opts.Stats.add(0, 0, len(batch), 0)
+                    continue
+                }
+
+                // Each attempt gets its own timeout; retries wait out
+                // backoff and Retry-After between them
+                retries, err := opts.Retry.Do(ctx, func() error {
+                    reqCtx, cancel := withTimeout(ctx, opts.Timeout)
+                    defer cancel()
+                    return fetch(reqCtx, batch)
+                })
+                finish(batch, err)
+                if err != nil {
+                    opts.Stats.add(0, 0, len(batch), retries)
+                } else {
+                    opts.Stats.add(len(batch), 0, 0, retries)
+                }
+
+                mu.Lock()
+                done += len(batch)
+                log.Printf("Fetched weather data (progress: %d/%d)", done, len(pending))
+                mu.Unlock()
+            }
+        }()
+    }
+
+    for start := 0; start < len(pending); start += batchSize {
+        end := start + batchSize
+        if end > len(pending) {
+            end = len(pending)
+        }
+        jobs <- pending[start:end]
+    }
+    close(jobs)
+    wg.Wait()
+}
+
+func pointsAt(points []Point, indexes []int) []Point {
+    batch := make([]Point, len(indexes))
+    for j, i := range indexes {
+        batch[j] = points[i]
+    }
+    return batch
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+    if timeout <= 0 {
+        return context.WithCancel(ctx)
+    }
+    return context.WithTimeout(ctx, timeout)
+}
+
+// CellPoints returns the centroids of H3 cells, in order.
+func CellPoints(cells []string) []Point {
+    points := make([]Point, len(cells))
+    for i, cell := range cells {
+        center := h3.ToGeo(h3.FromString(cell))
+        points[i] = Point{Lat: center.Latitude, Lon: center.Longitude}
+    }
+    return points
+}
@@ -0,0 +1,76 @@
+package weather
+
+import (
+    "fmt"
+    "os"
+    "strings"
+)
+
+// propertyValues reads each GeoJSON property we know how to write out of
+// an observation. Keys match the Observation json tags.
+var propertyValues = map[string]func(Observation) interface{}{
+    "temperature":      func(o Observation) interface{} { return o.Temp },
+    "temp_min":         func(o Observation) interface{} { return o.TempMin },
+    "temp_max":         func(o Observation) interface{} { return o.TempMax },
+    "feels_like":       func(o Observation) interface{} { return o.FeelsLike },
+    "weather_code":     func(o Observation) interface{} { return o.Code },
+    "icon":             func(o Observation) interface{} { return o.Icon },
+    "humidity":         func(o Observation) interface{} { return o.Humidity },
+    "pressure":         func(o Observation) interface{} { return o.Pressure },
+    "wind_speed":       func(o Observation) interface{} { return o.WindSpeed },
+    "wind_deg":         func(o Observation) interface{} { return o.WindDeg },
+    "clouds":           func(o Observation) interface{} { return o.Clouds },
+    "precipitation_1h": func(o Observation) interface{} { return o.Precip1h },
+    "visibility":       func(o Observation) interface{} { return o.Visibility },
+    "sunrise":          func(o Observation) interface{} { return o.Sunrise },
+    "sunset":           func(o Observation) interface{} { return o.Sunset },
+    "observed_at":      func(o Observation) interface{} { return o.ObservedAt },
+}
+
+// PropertySet is the list of weather properties written on each feature.
+type PropertySet []string
+
+// DefaultProperties is every field the map pages style by.
+var DefaultProperties = PropertySet{
+    "temperature", "feels_like", "humidity", "pressure", "wind_speed",
+    "wind_deg", "clouds", "precipitation_1h", "visibility", "sunrise",
+    "sunset", "observed_at",
+}
+
+// PropertiesFromEnv reads a comma separated WEATHER_PROPERTIES, or "all"
+// for every known property. temperature is always included since the
+// parent generators read it back.
+func PropertiesFromEnv() (PropertySet, error) {
+    v := strings.TrimSpace(os.Getenv("WEATHER_PROPERTIES"))
+    switch v {
+    case "":
+        return DefaultProperties, nil
+    case "all":
+        v = "temperature,temp_min,temp_max,feels_like,weather_code,icon,humidity,pressure,wind_speed,wind_deg,clouds,precipitation_1h,visibility,sunrise,sunset,observed_at"
+    }
+
+    set := PropertySet{"temperature"}
+    for _, name := range strings.Split(v, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" || name == "temperature" {
+            continue
+        }
+        if _, ok := propertyValues[name]; !ok {
+            return nil, fmt.Errorf("unknown WEATHER_PROPERTIES field %q", name)
+        }
+        set = append(set, name)
+    }
+    return set, nil
+}
+
+// Apply writes the set's properties for a result into props. A failed
+// result gets null for every field, never a zero that looks real.
+func (s PropertySet) Apply(props map[string]interface{}, r Result) {
+    for _, name := range s {
+        if r.Err != nil {
+            props[name] = nil
+            continue
+        }
+        props[name] = propertyValues[name](r.Observation)
+    }
+}
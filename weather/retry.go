@@ -0,0 +1,181 @@
+package weather
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "math/rand"
+    "net"
+    "net/http"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// StatusError is a non-200 answer from a provider.
+type StatusError struct {
+    StatusCode int
+    RetryAfter time.Duration
+    Body       string
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("failed to fetch weather data: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// newStatusError builds a StatusError from a response, reading Retry-After
+// when the provider sends one (seconds or an HTTP date).
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+    err := &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+    if v := resp.Header.Get("Retry-After"); v != "" {
+        if secs, perr := strconv.Atoi(v); perr == nil {
+            err.RetryAfter = time.Duration(secs) * time.Second
+        } else if t, perr := http.ParseTime(v); perr == nil {
+            err.RetryAfter = time.Until(t)
+        }
+    }
+    return err
+}
+
+// RetryPolicy is exponential backoff with full jitter.
+type RetryPolicy struct {
+    MaxAttempts int
+    BaseDelay   time.Duration
+    MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 4,
+    BaseDelay:   500 * time.Millisecond,
+    MaxDelay:    30 * time.Second,
+}
+
+// RetryPolicyFromEnv overrides DefaultRetryPolicy with WEATHER_RETRIES
+// (total attempts) and WEATHER_RETRY_BASE (a Go duration).
+func RetryPolicyFromEnv() RetryPolicy {
+    policy := DefaultRetryPolicy
+    if v, err := strconv.Atoi(os.Getenv("WEATHER_RETRIES")); err == nil && v > 0 {
+        policy.MaxAttempts = v
+    }
+    if v, err := time.ParseDuration(os.Getenv("WEATHER_RETRY_BASE")); err == nil && v > 0 {
+        policy.BaseDelay = v
+    }
+    return policy
+}
+
+// retryable reports whether a failed request is worth repeating: network
+// errors, timeouts, 429 and 5xx. Bad keys and bad requests are not.
+func retryable(err error) bool {
+    var statusErr *StatusError
+    if errors.As(err, &statusErr) {
+        return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) {
+        return true
+    }
+    return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Do calls fn until it succeeds, fails permanently, runs out of attempts or
+// ctx is cancelled. It returns how many retries were needed.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+    attempts := p.MaxAttempts
+    if attempts <= 0 {
+        attempts = 1
+    }
+
+    var err error
+    for attempt := 0; attempt < attempts; attempt++ {
+        if err = fn(); err == nil || !retryable(err) || ctx.Err() != nil {
+            return attempt, err
+        }
+        if attempt == attempts-1 {
+            break
+        }
+
+        delay := p.BaseDelay << attempt
+        if delay > p.MaxDelay || delay <= 0 {
+            delay = p.MaxDelay
+        }
+        delay = time.Duration(rand.Int63n(int64(delay) + 1))
+
+        var statusErr *StatusError
+        if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+            delay = statusErr.RetryAfter
+        }
+
+        select {
+        case <-time.After(delay):
+        case <-ctx.Done():
+            return attempt, ctx.Err()
+        }
+    }
+    return attempts - 1, err
+}
+
+// RunStats counts fetch outcomes across every FetchAll of a run.
+type RunStats struct {
+    // MaxFailureRate is the share of failed points (0-1) above which
+    // Check fails the job.
+    MaxFailureRate float64
+
+    mu      sync.Mutex
+    fetched int
+    cached  int
+    failed  int
+    retries int
+}
+
+// NewRunStats reads the failure threshold from WEATHER_MAX_FAILURE_RATE,
+// defaulting to 0.2.
+func NewRunStats() *RunStats {
+    stats := &RunStats{MaxFailureRate: 0.2}
+    if v, err := strconv.ParseFloat(os.Getenv("WEATHER_MAX_FAILURE_RATE"), 64); err == nil && v >= 0 {
+        stats.MaxFailureRate = v
+    }
+    return stats
+}
+
+func (s *RunStats) add(fetched, cached, failed, retries int) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.fetched += fetched
+    s.cached += cached
+    s.failed += failed
+    s.retries += retries
+}
+
+// LogSummary prints the outcome of the run.
+func (s *RunStats) LogSummary() {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    log.Printf("Weather summary: %d fetched, %d from cache, %d failed, %d retries", s.fetched, s.cached, s.failed, s.retries)
+}
+
+// Check returns an error when more than MaxFailureRate of the points
+// failed, so the job exits non-zero instead of publishing a broken map.
+func (s *RunStats) Check() error {
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    total := s.fetched + s.cached + s.failed
+    if total == 0 {
+        return nil
+    }
+    rate := float64(s.failed) / float64(total)
+    if rate > s.MaxFailureRate {
+        return fmt.Errorf("%d of %d weather fetches failed (%.1f%%, limit %.1f%%)", s.failed, total, 100*rate, 100*s.MaxFailureRate)
+    }
+    return nil
+}
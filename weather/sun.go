@@ -0,0 +1,74 @@
+package weather
+
+import (
+    "math"
+    "time"
+)
+
+// Phase is where the sun is for an observer: up, in civil twilight before
+// sunrise or after sunset, or more than 6° below the horizon.
+type Phase string
+
+const (
+    Day   Phase = "day"
+    Dawn  Phase = "dawn"
+    Dusk  Phase = "dusk"
+    Night Phase = "night"
+)
+
+// SunElevation returns the sun's elevation above the horizon in degrees at
+// a coordinate and time, and whether it is before local solar noon. It
+// uses the low precision solar coordinates from the Astronomical Almanac,
+// good to about a degree, which is plenty to tell day from night.
+func SunElevation(lat, lon float64, t time.Time) (elevation float64, morning bool) {
+    const rad = math.Pi / 180
+
+    // Days since J2000.0
+    d := float64(t.Unix())/86400 - 10957.5
+
+    g := (357.529 + 0.98560028*d) * rad
+    q := 280.459 + 0.98564736*d
+    l := (q + 1.915*math.Sin(g) + 0.020*math.Sin(2*g)) * rad
+    e := (23.439 - 0.00000036*d) * rad
+
+    ra := math.Atan2(math.Cos(e)*math.Sin(l), math.Cos(l)) / rad
+    decl := math.Asin(math.Sin(e) * math.Sin(l))
+
+    gmst := math.Mod(18.697374558+24.06570982441908*d, 24)
+    hourAngle := math.Mod(gmst*15+lon-ra+540, 360) - 180
+
+    sinElevation := math.Sin(lat*rad)*math.Sin(decl) + math.Cos(lat*rad)*math.Cos(decl)*math.Cos(hourAngle*rad)
+    return math.Asin(sinElevation) / rad, hourAngle < 0
+}
+
+// PhaseAt returns the day phase at a coordinate and time. Sunrise and
+// sunset are taken at -0.833° to allow for refraction and the sun's disc.
+func PhaseAt(lat, lon float64, t time.Time) Phase {
+    elevation, morning := SunElevation(lat, lon, t)
+    switch {
+    case elevation > -0.833:
+        return Day
+    case elevation > -6:
+        if morning {
+            return Dawn
+        }
+        return Dusk
+    default:
+        return Night
+    }
+}
+
+// PhaseEmojis is a condition's emoji at each phase of the day, as listed
+// under "phases" for every code in weather_codes.json. Clear and partly
+// cloudy skies change with the sun, mist and fog lose the sunlit bridge at
+// night, and precipitation, storms and overcast skies look the same at any
+// hour.
+type PhaseEmojis map[Phase]string
+
+// At returns the emoji for phase, falling back to the day one.
+func (e PhaseEmojis) At(phase Phase) string {
+    if emoji, ok := e[phase]; ok {
+        return emoji
+    }
+    return e[Day]
+}
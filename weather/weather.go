@@ -0,0 +1,78 @@
+// Package weather fetches current conditions for a coordinate through a
+// pluggable Provider so the weather jobs don't each talk to an API.
+package weather
+
+import (
+    "context"
+    "fmt"
+    "os"
+)
+
+// Observation is a single weather reading for a point. Code uses the
+// OpenWeatherMap condition ids that weather_codes.json is keyed by. Units
+// are metric: °C, %, hPa, m/s, mm and metres; times are Unix seconds.
+type Observation struct {
+    Temp       float64 `json:"temperature"`
+    TempMin    float64 `json:"temp_min"`
+    TempMax    float64 `json:"temp_max"`
+    FeelsLike  float64 `json:"feels_like"`
+    Code       int     `json:"weather_code"`
+    Icon       string  `json:"icon"`
+    Humidity   float64 `json:"humidity"`
+    Pressure   float64 `json:"pressure"`
+    WindSpeed  float64 `json:"wind_speed"`
+    WindDeg    float64 `json:"wind_deg"`
+    Clouds     float64 `json:"clouds"`
+    Precip1h   float64 `json:"precipitation_1h"`
+    Visibility float64 `json:"visibility"`
+    Sunrise    int64   `json:"sunrise"`
+    Sunset     int64   `json:"sunset"`
+    ObservedAt int64   `json:"observed_at"`
+}
+
+// Point is a coordinate to fetch weather for.
+type Point struct {
+    Lat float64
+    Lon float64
+}
+
+// Provider returns the current weather at a coordinate.
+type Provider interface {
+    Name() string
+    Current(ctx context.Context, lat, lon float64) (Observation, error)
+}
+
+// BatchProvider is implemented by providers that can answer many
+// coordinates in one round trip. Results are in the same order as points.
+type BatchProvider interface {
+    Provider
+    CurrentBatch(ctx context.Context, points []Point) ([]Observation, error)
+}
+
+// FromEnv builds the provider named by WEATHER_PROVIDER, defaulting to
+// OpenWeatherMap, so each run can pick its backend.
+func FromEnv() (Provider, error) {
+    return New(os.Getenv("WEATHER_PROVIDER"))
+}
+
+// New builds a provider by name, reading its settings from the environment.
+func New(name string) (Provider, error) {
+    switch name {
+    case "", "openweathermap":
+        apiKey := os.Getenv("OPENWEATHERMAP_API_KEY")
+        if apiKey == "" {
+            return nil, fmt.Errorf("OPENWEATHERMAP_API_KEY not set in environment")
+        }
+        return NewOpenWeatherMap(apiKey), nil
+    case "open-meteo":
+        om := NewOpenMeteo(os.Getenv("OPEN_METEO_URL"))
+        if v := os.Getenv("OPEN_METEO_ARCHIVE_URL"); v != "" {
+            om.ArchiveURL = v
+        }
+        return om, nil
+    case "fake":
+        return Fake{}, nil
+    default:
+        return nil, fmt.Errorf("unknown weather provider %q", name)
+    }
+}